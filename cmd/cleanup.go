@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/doctor"
+	"s3syn-test/internal/load"
+	"s3syn-test/internal/probes"
+	"s3syn-test/internal/s3lib"
+	"s3syn-test/internal/trigger"
+)
+
+// runCleanup удаляет объекты проб старше CLEANUP_MIN_AGE, оставшиеся после прерванных сценариев,
+// и возвращает код возврата.
+func runCleanup(ctx context.Context, cfg *config.Config) int {
+	// Пробы, созданные через API управления, берутся из файла состояния
+	manager, err := probes.New(cfg, nil, nil, cfg.ProbesStateFile, nil)
+	if err != nil {
+		cfg.Logger.Error("Failed to load probes", slog.Any("error", err))
+		return 1
+	}
+	if !cleanupOrphans(ctx, cfg, manager.Entries()) {
+		return 1
+	}
+	return 0
+}
+
+// cleanupOrphans удаляет объекты всех целей, выбранные cleanupKeys, измененные раньше CLEANUP_MIN_AGE,
+// и прерывает такие же старые multipart upload. Возвращает false, если очистка хотя бы одной цели не удалась.
+func cleanupOrphans(ctx context.Context, cfg *config.Config, entries []probes.Entry) bool {
+	olderThan := time.Now().Add(-cfg.Cleanup.MinAge)
+	ok := true
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		prefixes, match := cleanupKeys(cfg, target, entries)
+		stats, err := s3lib.CleanupOrphans(ctx, cfg, target, prefixes, match, olderThan, cfg.Cleanup.DryRun)
+		if err != nil {
+			cfg.Logger.Error("Failed to clean up orphaned objects", slog.String("target", target.Name), slog.Any("error", err))
+			ok = false
+			continue
+		}
+		cfg.Logger.Info("Orphaned objects cleaned up", slog.String("target", target.Name), slog.Bool("dry_run", cfg.Cleanup.DryRun),
+			slog.Int("objects", stats.Objects), slog.Int64("bytes", stats.Bytes), slog.Int("multipart_uploads", stats.Uploads))
+	}
+	return ok
+}
+
+// cleanupKeys возвращает префиксы, под которыми ищутся оставшиеся объекты цели, и проверку ключа объекта.
+// С CLEANUP_PREFIXES удаляются все ключи под префиксами. По умолчанию удаляются только ключи проб цели и ключи,
+// которые генерируют запуски по запросу, нагрузочные режимы, разовые пробы и doctor.
+// Посторонние объекты с похожими ключами, например file1kb-report.pdf, не удаляются.
+func cleanupKeys(cfg *config.Config, target *config.Target, entries []probes.Entry) ([]string, func(key string) bool) {
+	if len(cfg.Cleanup.Prefixes) > 0 {
+		return cfg.Cleanup.Prefixes, nil
+	}
+	generated := []string{trigger.KeyPrefix, doctor.KeyPrefix}
+	prefixes := append(load.KeyPrefixes(), generated...)
+	exact := make(map[string]bool)
+	for _, e := range entries {
+		if e.Target.Name != target.Name {
+			continue
+		}
+		key := e.Probe.ObjectKey()
+		exact[key] = true
+		prefixes = append(prefixes, key)
+		generated = append(generated, key+trigger.OnDemandInfix, key+load.ScenarioInfix)
+	}
+	return prefixes, func(key string) bool {
+		if exact[key] || load.IsKey(key) {
+			return true
+		}
+		for _, prefix := range generated {
+			if suffix, ok := strings.CutPrefix(key, prefix); ok && isNumber(suffix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isNumber сообщает, состоит ли s только из цифр.
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// runCleanupLoop периодически удаляет оставшиеся объекты текущих целей и проб manager, пока не будет отменен ctx.
+func runCleanupLoop(ctx context.Context, cfg *config.Config, manager *probes.Manager) {
+	ticker := time.NewTicker(cfg.Cleanup.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanupOrphans(ctx, manager.Config(), manager.Entries())
+		}
+	}
+}
+
+// cleanupProbes удаляет объекты проб и прерывает их незавершенные multipart upload.
+// Возвращает false, если очистить объекты хотя бы одной пробы не удалось.
+func cleanupProbes(ctx context.Context, cfg *config.Config, entries []probes.Entry) bool {
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s3lib.CleanupProbe(ctx, cfg, e.Target, &e.Probe); err != nil {
+				cfg.Logger.Warn("Failed to clean up probe objects", slog.String("target", e.Target.Name), slog.String("file", e.Probe.FileName), slog.Any("error", err))
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	return !failed.Load()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/doctor"
+)
+
+// runDoctor проверяет подключение к каждой цели, выводит отчет и возвращает 1, если хотя бы одна проверка не прошла.
+func runDoctor(ctx context.Context, cfg *config.Config) int {
+	reports := make([]doctor.Report, 0, len(cfg.Targets))
+	failed := false
+	for i := range cfg.Targets {
+		report := doctor.Diagnose(ctx, cfg, &cfg.Targets[i])
+		failed = failed || report.Failed()
+		reports = append(reports, report)
+	}
+	doctor.WriteText(os.Stdout, reports)
+	if failed {
+		return 1
+	}
+	return 0
+}
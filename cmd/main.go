@@ -1,67 +1,219 @@
 package main
 
 import (
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"log/slog"
-	"net/http"
-	_ "net/http/pprof" // Включение поддержки pprof
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-	"s3syn-test/internal/health"
-	"s3syn-test/internal/metrics"
-	"sync"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"s3syn-test/internal/config"
-	"s3syn-test/internal/s3lib"
+	"s3syn-test/internal/metrics"
 )
 
-func main() {
-	cfg := config.MustLoad()
-	metrics.Init()
+// envFlag - флаг командной строки, переопределяющий переменную окружения.
+type envFlag struct {
+	name  string
+	env   string
+	usage string
+}
 
-	// Инициализация HTTP сервера для метрик и health checks
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+// command - подкоманда приложения.
+type command struct {
+	name  string
+	usage string
+	flags []envFlag
+	bools []envFlag // Флаги без значения, переменной присваивается true или false
+	mode  string    // Режим работы команды, пустая строка - режим из MODE
+	run   func(ctx context.Context, stop context.CancelFunc, cfg *config.Config) int
 
-	// Регистрируем health handlers
-	healthChecker, err := health.NewHealthChecker(cfg)
-	if err != nil {
-		cfg.Logger.Error("Failed to init health checker", slog.Any("error", err))
-		os.Exit(11)
+	selfLoad bool // Команда сама загружает конфигурацию, в run передается nil
+}
+
+// Флаги, общие для всех команд.
+var commonFlags = []envFlag{
+	{"targets", "TARGETS", "имена целей через запятую"},
+	{"endpoint", "S3_ENDPOINT", "эндпоинт S3"},
+	{"region", "S3_REGION", "регион S3"},
+	{"bucket", "S3_BUCKET", "бакет"},
+	{"files", "FILE_PATTERNS", "имена файлов проб через запятую"},
+	{"sizes", "FILE_SIZES", "размеры файлов проб через запятую: байты или 4KiB, 1.5MB"},
+	{"upload-timeouts", "UPLOAD_TIMEOUTS", "таймауты загрузки через запятую: секунды или 250ms, 1m30s"},
+	{"download-timeouts", "DOWNLOAD_TIMEOUTS", "таймауты скачивания через запятую: секунды или 250ms, 1m30s"},
+	{"delete-timeouts", "DELETE_TIMEOUTS", "таймауты удаления через запятую: секунды или 250ms, 1m30s"},
+	{"files-dir", "FILES_DIR", "каталог временных файлов"},
+	{"log-level", "LOG_LEVEL", "уровень логирования"},
+	{"log-format", "LOG_FORMAT", "формат логов: json или text"},
+}
+
+func commands() []command {
+	return []command{
+		{
+			name:  "run",
+			usage: "выполнять пробы по расписанию (или в режиме из MODE) с HTTP серверами метрик и проверок",
+			flags: []envFlag{
+				{"interval", "TASK_INTERVAL", "интервал между итерациями проб: секунды или 30s, 5m"},
+				{"schedules", "SCHEDULES", "расписания проб через ';'"},
+			},
+			bools: []envFlag{{"profiler", "PROFILER", "запустить pprof на :6060"}},
+			run:   serve,
+		},
+		{
+			name:  "once",
+			usage: "выполнить каждую пробу один раз и завершиться с кодом 0 или 2",
+			flags: []envFlag{{"report", "ONCE_REPORT", "файл для JSON отчета"}},
+			mode:  config.ModeOnce,
+			run: func(ctx context.Context, _ context.CancelFunc, cfg *config.Config) int {
+				return runOnce(ctx, cfg)
+			},
+		},
+		{
+			name:  "bench",
+			usage: "выполнить нагрузку (load) или, с -ramp, поиск точки насыщения (ramp) и вывести отчет",
+			flags: []envFlag{
+				{"workers", "LOAD_WORKERS", "число воркеров"},
+				{"duration", "LOAD_DURATION", "длительность нагрузки: секунды или 2m"},
+				{"operations", "LOAD_OPERATIONS", "число операций"},
+				{"rate", "LOAD_RATE", "ограничение темпа операций в секунду"},
+				{"workload", "WORKLOAD", "веса операций смешанной нагрузки"},
+				{"objects", "WORKLOAD_OBJECTS", "число объектов смешанной нагрузки"},
+				{"size-distribution", "SIZE_DISTRIBUTION", "распределение размеров объектов"},
+				{"ramp-workers", "RAMP_WORKERS", "ступени числа воркеров через запятую"},
+				{"ramp-sizes", "RAMP_SIZES", "размеры объектов ramp через запятую: байты или 4KiB, 64MiB"},
+				{"step-duration", "RAMP_STEP_DURATION", "длительность ступени: секунды или 30s"},
+				{"output", "RAMP_OUTPUT", "формат отчета ramp: table или csv"},
+			},
+			mode: config.ModeLoad,
+			run:  serve,
+		},
+		{
+			name:  "cleanup",
+			usage: "удалить объекты проб и незавершенные multipart upload старше -min-age, оставшиеся после прерванных сценариев",
+			flags: []envFlag{
+				{"prefixes", "CLEANUP_PREFIXES", "префиксы ключей через запятую, по умолчанию ключи, которые создает сервис"},
+				{"min-age", "CLEANUP_MIN_AGE", "минимальный возраст удаляемых объектов: секунды или 1h"},
+			},
+			bools: []envFlag{{"dry-run", "CLEANUP_DRY_RUN", "только подсчитать объекты, не удаляя их"}},
+			mode:  config.ModeDaemon,
+			run: func(ctx context.Context, _ context.CancelFunc, cfg *config.Config) int {
+				return runCleanup(ctx, cfg)
+			},
+		},
+		{
+			name:  "doctor",
+			usage: "проверить DNS, TCP, TLS, часы, учетные данные, доступ к бакету и права на запись, чтение и удаление",
+			mode:  config.ModeDaemon,
+			run: func(ctx context.Context, _ context.CancelFunc, cfg *config.Config) int {
+				return runDoctor(ctx, cfg)
+			},
+		},
+		{
+			name:     "validate",
+			usage:    "проверить конфигурацию, вывести все найденные ошибки и завершиться",
+			selfLoad: true,
+			run: func(context.Context, context.CancelFunc, *config.Config) int {
+				return runValidate()
+			},
+		},
 	}
-	mux.HandleFunc("/healthz", healthChecker.HandleLiveness)
-	mux.HandleFunc("/ready", healthChecker.HandleReadiness)
+}
 
-	// Запускаем сервер для метрик и health checks
-	go func() {
-		cfg.Logger.Info("Starting metrics and health server on :8080")
-		if err = http.ListenAndServe(":8080", mux); err != nil {
-			cfg.Logger.Error("Failed to start metrics and health server", slog.Any("error", err))
-			os.Exit(12)
+func main() {
+	// Без команды приложение работает как раньше: режим задается переменной MODE
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmds := commands()
+	var cmd *command
+	for i := range cmds {
+		if cmds[i].name == name {
+			cmd = &cmds[i]
 		}
-	}()
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage(cmds)
+		os.Exit(1)
+	}
+	if err := parseFlags(cmd, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
 
-	if cfg.Profiler {
-		// Запускаем сервер для pprof
-		go func() {
-			cfg.Logger.Info("Starting profiler server on :6060")
-			if err = http.ListenAndServe(":6060", nil); err != nil {
-				cfg.Logger.Error("Failed to start profiler server", slog.Any("error", err))
-				os.Exit(13)
-			}
-		}()
+	var cfg *config.Config
+	if !cmd.selfLoad {
+		cfg = config.MustLoad()
 	}
+	metrics.Init()
+
+	// ctx отменяется по SIGINT/SIGTERM и останавливает запуск новых итераций
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	code := cmd.run(ctx, stop, cfg)
+	stop()
+	os.Exit(code)
+}
 
-	for {
-		var wg sync.WaitGroup
-		for i, fileName := range cfg.FileNames {
-			wg.Add(1)
-			go func(i int, fileName string) {
-				defer wg.Done()
-				s3lib.ProcessFile(cfg, cfg.TempFiles[i], fileName, cfg.FileSizesBytes[i], cfg.UploadTimeoutSecs[i], cfg.DownloadTimeoutSecs[i], cfg.DeleteTimeoutSecs[i])
-			}(i, fileName)
+// parseFlags разбирает флаги команды и записывает заданные значения в переменные окружения,
+// из которых затем загружается конфигурация. Режим команды также передается через MODE.
+func parseFlags(cmd *command, args []string) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags := append(append([]envFlag{}, commonFlags...), cmd.flags...)
+	for _, f := range flags {
+		fs.String(f.name, "", fmt.Sprintf("%s (%s)", f.usage, f.env))
+	}
+	for _, f := range cmd.bools {
+		fs.Bool(f.name, false, fmt.Sprintf("%s (%s)", f.usage, f.env))
+	}
+	flags = append(flags, cmd.bools...)
+	if cmd.name == "bench" {
+		fs.Bool("ramp", false, "искать точку насыщения вместо нагрузки с постоянным числом воркеров")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: s3syn-test %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "Unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return errors.New("unexpected arguments")
+	}
+
+	envs := make(map[string]string, len(flags))
+	for _, f := range flags {
+		envs[f.name] = f.env
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if env, ok := envs[f.Name]; ok && err == nil {
+			err = os.Setenv(env, f.Value.String())
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if cmd.mode != "" {
+		mode := cmd.mode
+		if f := fs.Lookup("ramp"); f != nil && f.Value.String() == "true" {
+			mode = config.ModeRamp
 		}
-		wg.Wait()
-		time.Sleep(time.Duration(cfg.TaskInterval) * time.Second)
+		return os.Setenv("MODE", mode)
+	}
+	return nil
+}
+
+func usage(cmds []command) {
+	fmt.Fprintln(os.Stderr, "Usage: s3syn-test <command> [flags]\n\nCommands:")
+	for _, cmd := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun 's3syn-test <command> -h' for command flags. Flags override environment variables.")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/once"
+	"s3syn-test/internal/probes"
+)
+
+// runOnce выполняет каждую пробу один раз, выводит таблицу шагов в stderr и JSON отчет в stdout или ONCE_REPORT
+// и возвращает код возврата: 0, если все пробы прошли успешно, и 2, если хотя бы один шаг завершился ошибкой,
+// таймаутом или не прошел проверку целостности.
+func runOnce(ctx context.Context, cfg *config.Config) int {
+	if !createTempFiles(cfg) {
+		return 1
+	}
+	summary := once.Run(ctx, cfg)
+
+	// Объекты неуспешных проб могли остаться в бакете
+	if !summary.OK {
+		cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		cleanupProbes(cleanupCtx, cfg, probes.ConfigEntries(cfg))
+		cancelCleanup()
+	}
+	cfg.RemoveTempFiles()
+
+	// stdout содержит только JSON отчет, чтобы его можно было передать, например, в jq
+	summary.WriteText(os.Stderr)
+	report := os.Stdout
+	if cfg.OnceReport != "" {
+		file, err := os.Create(cfg.OnceReport)
+		if err != nil {
+			cfg.Logger.Error("Failed to create report file", slog.String("file", cfg.OnceReport), slog.Any("error", err))
+			return 1
+		}
+		defer file.Close()
+		report = file
+	}
+	if err := summary.WriteJSON(report); err != nil {
+		cfg.Logger.Error("Failed to write report", slog.Any("error", err))
+		return 1
+	}
+	if !summary.OK {
+		return 2
+	}
+	return 0
+}
+
+// createTempFiles создает локальные файлы проб. При ошибке записывает ее в лог, удаляет уже созданные файлы
+// и возвращает false.
+func createTempFiles(cfg *config.Config) bool {
+	if err := cfg.CreateTempFiles(); err != nil {
+		cfg.Logger.Error("Failed to create probe files", slog.Any("error", err))
+		cfg.RemoveTempFiles()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/health"
+	"s3syn-test/internal/metrics"
+	"s3syn-test/internal/probes"
+)
+
+// runReloader перезагружает конфигурацию при получении сигнала из hup и, если задан CONFIG_FILE,
+// при изменении содержимого файла, пока не будет отменен ctx.
+func runReloader(ctx context.Context, cfg *config.Config, manager *probes.Manager, healthChecker *health.HealthChecker, hup chan os.Signal) {
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if cfg.ConfigFile != "" && cfg.ReloadInterval > 0 {
+		// Содержимое сравнивается, а не время изменения: ConfigMap в Kubernetes обновляется заменой символической ссылки
+		ticker := time.NewTicker(cfg.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	hash := fileHash(cfg.ConfigFile)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cfg.Logger.Info("Received SIGHUP, reloading configuration")
+		case <-tick:
+			if fileHash(cfg.ConfigFile) == hash {
+				continue
+			}
+			cfg.Logger.Info("Configuration file changed, reloading configuration", slog.String("file", cfg.ConfigFile))
+		}
+		hash = fileHash(cfg.ConfigFile)
+		reloadConfig(cfg, manager, healthChecker)
+	}
+}
+
+// reloadConfig загружает и проверяет новую конфигурацию и применяет к работающему процессу цели, пробы,
+// параметры readiness, SHUTDOWN_TIMEOUT и уровень логирования. Если конфигурация неверна или изменяет
+// параметры, требующие перезапуска, продолжает работать текущая.
+func reloadConfig(cfg *config.Config, manager *probes.Manager, healthChecker *health.HealthChecker) {
+	next, err := config.Load()
+	if err == nil {
+		err = restartRequired(cfg, next)
+	}
+	var targets []health.TargetSession
+	if err == nil {
+		next.Logger = cfg.Logger
+		targets, err = health.NewTargetSessions(next)
+	}
+	if err == nil {
+		// Объекты удаленных проб удаляются из бакета так же, как при остановке
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		err = manager.Reload(ctx, next)
+		cancel()
+	}
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		cfg.Logger.Error("Invalid configuration, keeping the running one", slog.Any("error", err))
+		return
+	}
+	healthChecker.SetConfig(next, targets)
+	cfg.SetLogLevel(next.LogLevel)
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	cfg.Logger.Info("Configuration reloaded", slog.Int("targets", len(next.Targets)), slog.Int("probes", len(manager.Entries())))
+}
+
+// restartRequired возвращает ошибку, если next изменяет параметры, которые работающий процесс не может
+// применить без перезапуска: так оператор узнает, что нужен рестарт, а не считает изменение примененным.
+func restartRequired(cfg, next *config.Config) error {
+	var changed []string
+	for _, setting := range []struct {
+		name    string
+		changed bool
+	}{
+		{"MODE", next.Mode != cfg.Mode},
+		{"STATUS_HISTORY", next.StatusHistory != cfg.StatusHistory},
+		{"CLEANUP_INTERVAL", next.Cleanup.Interval != cfg.Cleanup.Interval},
+		{"CONFIG_RELOAD_INTERVAL", next.ReloadInterval != cfg.ReloadInterval},
+		{"API_TOKEN", next.APIToken != cfg.APIToken},
+		{"PROBES_STATE_FILE", next.ProbesStateFile != cfg.ProbesStateFile},
+		{"PROFILER", next.Profiler != cfg.Profiler},
+	} {
+		if setting.changed {
+			changed = append(changed, setting.name)
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%s: cannot be changed without restart", strings.Join(changed, ", "))
+	}
+	// Хранилище результатов создано при запуске и хранит не больше выполнений каждой пробы
+	if history := max(cfg.StatusHistory, cfg.ReadinessRuns); next.ReadinessRuns > history {
+		return fmt.Errorf("READINESS_RUNS: cannot exceed %d runs kept by the running process without restart", history)
+	}
+	return nil
+}
+
+// fileHash возвращает хеш содержимого файла или пустую строку, если файл не задан или не читается.
+func fileHash(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof" // Включение поддержки pprof
+	"os"
+	"os/signal"
+	"s3syn-test/internal/dashboard"
+	"s3syn-test/internal/health"
+	"s3syn-test/internal/load"
+	"s3syn-test/internal/probes"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/trigger"
+	"s3syn-test/internal/watchdog"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/s3lib"
+)
+
+// serve выполняет пробы по расписанию (daemon) или нагрузку (load, ramp), обслуживая HTTP серверы метрик
+// и проверок, до завершения работы или отмены ctx. stop восстанавливает обработку сигналов по умолчанию,
+// чтобы повторный сигнал во время остановки завершал процесс.
+func serve(ctx context.Context, stop context.CancelFunc, cfg *config.Config) int {
+	if cfg.Mode == config.ModeOnce {
+		// MODE=once без команды: однократный запуск без HTTP серверов
+		return runOnce(ctx, cfg)
+	}
+	if !createTempFiles(cfg) {
+		return 1
+	}
+	defer cfg.RemoveTempFiles()
+
+	// Хранилище результатов выполнения сценариев проб
+	store := results.NewStore(max(cfg.StatusHistory, cfg.ReadinessRuns))
+
+	// Watchdog считает итерацию пробы зависшей, если она не завершилась за LIVENESS_MULTIPLIER периодов
+	// ее расписания плюс суммарная длительность таймаутов пробы
+	wd := watchdog.New()
+	events := results.NewEventStream()
+	observer := results.Observers{wd, events}
+
+	// probeCtx отменяется, только если выполняющиеся пробы не успели завершиться за SHUTDOWN_TIMEOUT
+	probeCtx, cancelProbes := context.WithCancel(context.Background())
+	defer cancelProbes()
+
+	// Пробы режима daemon; через API управления их можно изменять во время работы. Итерации выполняются
+	// с текущей конфигурацией manager, чтобы общие параметры применялись после перезагрузки
+	var manager *probes.Manager
+	manager, err := probes.New(cfg, store, wd, cfg.ProbesStateFile, func(target *config.Target, probe *config.Probe) {
+		store.Record(s3lib.ProcessFile(probeCtx, manager.Config(), target, probe, observer))
+		wd.IterationFinished(results.Key{Target: target.Name, File: probe.FileName})
+	})
+	if err != nil {
+		cfg.Logger.Error("Failed to load probes", slog.Any("error", err))
+		return 1
+	}
+
+	// Инициализация HTTP сервера для метрик и health checks
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Регистрируем health handlers
+	healthChecker, err := health.NewHealthChecker(cfg, store, wd)
+	if err != nil {
+		cfg.Logger.Error("Failed to init health checker", slog.Any("error", err))
+		return 11
+	}
+	mux.HandleFunc("/healthz", healthChecker.HandleLiveness)
+	mux.HandleFunc("/ready", healthChecker.HandleReadiness)
+	mux.HandleFunc("/status", store.HandleStatus)
+	mux.HandleFunc("/events", events.HandleEvents)
+	// Все маршруты /probes требуют API_TOKEN, без него они не регистрируются. Запуск пробы по запросу
+	// не передает уведомления о шагах watchdog, так как не относится к расписанию
+	if cfg.APIToken == "" {
+		cfg.Logger.Info("API_TOKEN is not set, /probes API is disabled")
+	} else {
+		auth := func(handler http.HandlerFunc) http.HandlerFunc { return probes.Authorize(cfg.APIToken, handler) }
+		mux.HandleFunc("POST /probes/run", auth(trigger.New(cfg, store, events, manager).HandleRun))
+		// Управление пробами доступно только в режиме daemon, где пробы выполняются по расписанию
+		if cfg.Mode == config.ModeDaemon {
+			mux.HandleFunc("GET /probes", auth(manager.HandleList))
+			mux.HandleFunc("POST /probes", auth(manager.HandleCreate))
+			mux.HandleFunc("GET /probes/{target}/{file}", auth(manager.HandleGet))
+			mux.HandleFunc("PUT /probes/{target}/{file}", auth(manager.HandleUpdate))
+			mux.HandleFunc("DELETE /probes/{target}/{file}", auth(manager.HandleDelete))
+			mux.HandleFunc("POST /probes/{target}/{file}/pause", auth(manager.HandlePause))
+			mux.HandleFunc("POST /probes/{target}/{file}/resume", auth(manager.HandleResume))
+		}
+	}
+	mux.HandleFunc("/{$}", dashboard.Handle)
+
+	// Код возврата задает первая ошибка; ошибка HTTP сервера также останавливает работу, как сигнал
+	var exitCode atomic.Int32
+	setCode := func(code int32) { exitCode.CompareAndSwap(0, code) }
+	fail := func(code int32) {
+		setCode(code)
+		stop()
+	}
+
+	// Запускаем сервер для метрик и health checks
+	server := &http.Server{Addr: ":8080", Handler: mux}
+	server.RegisterOnShutdown(events.Close)
+	go func() {
+		cfg.Logger.Info("Starting metrics and health server on :8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			cfg.Logger.Error("Failed to start metrics and health server", slog.Any("error", err))
+			fail(12)
+		}
+	}()
+
+	var profiler *http.Server
+	if cfg.Profiler {
+		// Запускаем сервер для pprof
+		profiler = &http.Server{Addr: ":6060", Handler: http.DefaultServeMux}
+		go func() {
+			cfg.Logger.Info("Starting profiler server on :6060")
+			if err := profiler.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				cfg.Logger.Error("Failed to start profiler server", slog.Any("error", err))
+				fail(13)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	var cleanup func(ctx context.Context)
+	switch cfg.Mode {
+	case config.ModeLoad:
+		if len(cfg.Load.Workload) > 0 {
+			// Смешанная нагрузка над заранее загруженным набором объектов
+			workload := load.NewWorkload(probeCtx, cfg)
+			cleanup = workload.Cleanup
+			go func() {
+				defer close(done)
+				if err := workload.Prepare(ctx, cfg.Load.Workers); err != nil {
+					cfg.Logger.Error("Failed to upload workload objects", slog.Any("error", err))
+					setCode(1)
+					return
+				}
+				setCode(loadCode(load.Run(ctx, cfg, cfg.Load, workload.Task)))
+			}()
+			break
+		}
+		// Нагрузочный режим: сценарий проб выполняется воркерами, по завершении выводится отчет
+		scenario := load.NewScenario(probeCtx, cfg, cfg.Load.Workers)
+		cleanup = scenario.Cleanup
+		go func() {
+			defer close(done)
+			setCode(loadCode(load.Run(ctx, cfg, cfg.Load, scenario.Task)))
+		}()
+	case config.ModeRamp:
+		// Поиск точки насыщения: нагрузка ступенями, по завершении выводится кривая пропускной способности
+		ramp := load.NewRamp(probeCtx, cfg)
+		cleanup = ramp.Cleanup
+		go func() {
+			defer close(done)
+			steps, err := ramp.Run(ctx)
+			if err != nil {
+				cfg.Logger.Error("Ramp stopped", slog.Any("error", err))
+				setCode(1)
+			}
+			if err := load.WriteRamp(os.Stdout, cfg.Ramp.Output, steps); err != nil {
+				cfg.Logger.Error("Failed to write ramp results", slog.Any("error", err))
+				setCode(1)
+			}
+		}()
+	default:
+		// Каждая проба запускается в собственном цикле по своему расписанию
+		if err := manager.Start(ctx); err != nil {
+			cfg.Logger.Error("Invalid probe schedule", slog.Any("error", err))
+			return 1
+		}
+		go func() {
+			manager.Wait()
+			close(done)
+		}()
+
+		// Перезагрузка конфигурации по SIGHUP и при изменении CONFIG_FILE
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go runReloader(ctx, cfg, manager, healthChecker, hup)
+		cleanup = func(ctx context.Context) { cleanupProbes(ctx, manager.Config(), manager.Entries()) }
+		if cfg.Cleanup.Interval > 0 {
+			// Периодическая очистка объектов, оставшихся после прерванных сценариев, в том числе других экземпляров
+			go runCleanupLoop(ctx, cfg, manager)
+		}
+	}
+
+	shutdownTimeout := cfg.ShutdownTimeout
+	select {
+	case <-done:
+	case <-ctx.Done():
+		stop()
+		cfg.Logger.Info("Gracefully shutting down...")
+		// SHUTDOWN_TIMEOUT мог измениться при перезагрузке конфигурации
+		shutdownTimeout = manager.Config().ShutdownTimeout
+
+		// Ждем завершения выполняющихся проб, по истечении SHUTDOWN_TIMEOUT прерываем их
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			cfg.Logger.Warn("Probes did not finish in time, canceling", slog.Duration("timeout", shutdownTimeout))
+			cancelProbes()
+			<-done
+		}
+	}
+
+	// Удаляем объекты проб и незавершенные multipart upload, которые могли остаться после прерванных сценариев
+	cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), shutdownTimeout)
+	cleanup(cleanupCtx)
+	cancelCleanup()
+
+	serverCtx, cancelServers := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelServers()
+	if err := server.Shutdown(serverCtx); err != nil {
+		cfg.Logger.Warn("Failed to shut down metrics and health server", slog.Any("error", err))
+	}
+	if profiler != nil {
+		if err := profiler.Shutdown(serverCtx); err != nil {
+			cfg.Logger.Warn("Failed to shut down profiler server", slog.Any("error", err))
+		}
+	}
+	cfg.Logger.Info("Shutdown complete")
+	return int(exitCode.Load())
+}
+
+// loadCode выводит отчет нагрузки и возвращает код возврата: 2, если хотя бы одна операция завершилась ошибкой,
+// как в режиме once, иначе 0.
+func loadCode(report *load.Report) int32 {
+	report.WriteText(os.Stdout)
+	if report.Failed > 0 {
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/schedule"
+)
+
+// runValidate загружает конфигурацию без создания файлов проб, проверяет расписания проб, выводит все
+// найденные ошибки или сводку конфигурации и возвращает код возврата.
+func runValidate() int {
+	cfg, err := config.Load()
+	var problems []config.FieldError
+	if err != nil {
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			fmt.Fprintf(os.Stderr, "Configuration is invalid: %s\n", err)
+			return 1
+		}
+		problems = validationErr.Errors
+	} else {
+		for _, target := range cfg.Targets {
+			for _, probe := range target.Probes {
+				if _, err := schedule.New(&probe); err != nil {
+					problems = append(problems, config.FieldError{Target: target.Name, Field: "probe " + probe.FileName, Message: err.Error()})
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "Configuration is invalid:")
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		return 1
+	}
+	probes := 0
+	for _, target := range cfg.Targets {
+		probes += len(target.Probes)
+	}
+	fmt.Fprintf(os.Stdout, "Configuration is valid: mode %s, %d targets, %d probes\n", cfg.Mode, len(cfg.Targets), probes)
+	return 0
+}
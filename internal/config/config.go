@@ -1,192 +1,981 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/ilyakaznacheev/cleanenv"
-	"log"
+	"github.com/robfig/cron/v3"
 	"log/slog"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
+	"time"
 )
 
+// EnvData - переменные окружения. Длительности задаются строкой Go ("250ms", "1m30s") или числом секунд
+// (RETRY_MIN_DELAYS и RETRY_MAX_DELAYS - миллисекунд), размеры - числом байт или с единицей ("4KiB", "1.5GB").
 type EnvData struct {
+	ConfigFile              string `env:"CONFIG_FILE"`                             // Файл переменных в формате KEY=VALUE, перечитывается при перезагрузке
+	ReloadInterval          string `env:"CONFIG_RELOAD_INTERVAL" env-default:"10"` // Проверка изменения CONFIG_FILE; 0 - только по SIGHUP
+	Targets                 string `env:"TARGETS"`                                 // Формат: "site1,site2"
 	S3Endpoint              string `env:"S3_ENDPOINT"`
 	S3Region                string `env:"S3_REGION"`
 	S3AccessKey             string `env:"S3_ACCESS_KEY"`
 	S3SecretKey             string `env:"S3_SECRET_KEY"`
 	S3Bucket                string `env:"S3_BUCKET"`
+	CredentialsProvider     string `env:"S3_CREDENTIALS_PROVIDER" env-default:"static"` // static, shared, web_identity, assume_role, process
+	S3AccessKeyFile         string `env:"S3_ACCESS_KEY_FILE"`
+	S3SecretKeyFile         string `env:"S3_SECRET_KEY_FILE"`
+	SharedCredentialsFile   string `env:"S3_SHARED_CREDENTIALS_FILE"`
+	Profile                 string `env:"S3_PROFILE"`
+	RoleARN                 string `env:"S3_ROLE_ARN"`
+	RoleSessionName         string `env:"S3_ROLE_SESSION_NAME" env-default:"s3syn-test"`
+	ExternalID              string `env:"S3_EXTERNAL_ID"`
+	WebIdentityTokenFile    string `env:"S3_WEB_IDENTITY_TOKEN_FILE"`
+	STSEndpoint             string `env:"S3_STS_ENDPOINT"`
+	CredentialProcess       string `env:"S3_CREDENTIAL_PROCESS"`
+	AddressingStyle         string `env:"S3_ADDRESSING_STYLE" env-default:"path"`      // path, virtual
+	SignatureVersion        string `env:"S3_SIGNATURE_VERSION" env-default:"v4"`       // v4, v2
+	PayloadSigning          string `env:"S3_PAYLOAD_SIGNING" env-default:"signed"`     // signed, unsigned, streaming
 	FilePatterns            string `env:"FILE_PATTERNS" env-default:"file1kb,file1mb"` // Формат: "file1.txt,file2.txt"
-	FileSizes               string `env:"FILE_SIZES" env-default:"1024,1048576"`       // Формат: "1024,2048" (в байтах)
-	UploadTimeouts          string `env:"UPLOAD_TIMEOUTS" env-default:"1,1"`
+	FileSizes               string `env:"FILE_SIZES" env-default:"1024,1048576"`       // Формат: "1024,4KiB,1.5MB" (число - в байтах)
+	UploadTimeouts          string `env:"UPLOAD_TIMEOUTS" env-default:"1,1"`           // Формат: "250ms,1m30s" (число - в секундах)
 	DownloadTimeouts        string `env:"DOWNLOAD_TIMEOUTS" env-default:"1,1"`
 	DeleteTimeouts          string `env:"DELETE_TIMEOUTS" env-default:"1,1"`
+	MaxRetries              string `env:"MAX_RETRIES"`      // Формат: "3,0"; 0 - без повторов
+	RetryMinDelays          string `env:"RETRY_MIN_DELAYS"` // Формат: "30,100ms" (число - в миллисекундах)
+	RetryMaxDelays          string `env:"RETRY_MAX_DELAYS"` // Формат: "1000,5s" (число - в миллисекундах)
+	RetryableCodes          string `env:"RETRYABLE_CODES"`  // Формат: "SlowDown|503,RequestError"
+	Schedules               string `env:"SCHEDULES"`        // Формат: "30;1m30s;*/5 * * * *" - интервал или cron выражение
+	ScheduleJitters         string `env:"SCHEDULE_JITTERS"` // Формат: "5,1m" (число - в секундах)
 	FilesDir                string `env:"FILES_DIR" env-default:"/tmp"`
 	LogFormat               string `env:"LOG_FORMAT" env-default:"json"`
 	LogLevel                string `env:"LOG_LEVEL" env-default:"info"`
-	MinFileSizeForMultipart int    `env:"MIN_FILE_SIZE_FOR_MULTIPART" env-default:"8388608"` // 8 MB
-	TaskInterval            int    `env:"TASK_INTERVAL" env-default:"60"`
-	Profiler                bool   `env:"PROFILER" env-default:"false"`
-	ConcurrencyMPU          int    `env:"CONCURRENCY_MPU" env-default:"3"`
+	MinFileSizeForMultipart string `env:"MIN_FILE_SIZE_FOR_MULTIPART" env-default:"8MiB"`
+	TaskInterval            string `env:"TASK_INTERVAL" env-default:"60"`
+	Profiler                string `env:"PROFILER" env-default:"false"`
+	ConcurrencyMPU          string `env:"CONCURRENCY_MPU" env-default:"3"`
+	ReadinessMode           string `env:"READINESS_MODE" env-default:"list_buckets"` // list_buckets, head_bucket, probes
+	ReadinessCacheTTL       string `env:"READINESS_CACHE_TTL" env-default:"0"`       // 0 - без кэширования
+	ReadinessTimeout        string `env:"READINESS_TIMEOUT" env-default:"5"`
+	ReadinessRuns           string `env:"READINESS_RUNS" env-default:"1"`
+	LivenessMultiplier      string `env:"LIVENESS_MULTIPLIER" env-default:"3"` // Лимит итерации: множитель периода расписания плюс таймауты пробы
+	StatusHistory           string `env:"STATUS_HISTORY" env-default:"20"`     // Число выполнений пробы в истории /status
+	ShutdownTimeout         string `env:"SHUTDOWN_TIMEOUT" env-default:"30"`   // Ожидание каждого этапа остановки
+	Mode                    string `env:"MODE" env-default:"daemon"`           // daemon, once, load, ramp
+	OnceReport              string `env:"ONCE_REPORT"`                         // Файл JSON отчета режима once, по умолчанию stdout
+	CleanupPrefixes         string `env:"CLEANUP_PREFIXES"`                    // Формат: "probes/,tmp-"; по умолчанию ключи, которые создает сервис
+	CleanupMinAge           string `env:"CLEANUP_MIN_AGE" env-default:"1h"`
+	CleanupDryRun           string `env:"CLEANUP_DRY_RUN" env-default:"false"`
+	CleanupInterval         string `env:"CLEANUP_INTERVAL" env-default:"0"` // 0 - без периодической очистки
+	APIToken                string `env:"API_TOKEN"`                        // Токен API управления пробами, без него API отключен
+	ProbesStateFile         string `env:"PROBES_STATE_FILE"`                // Файл состояния проб API управления, по умолчанию не сохраняется
+	LoadWorkers             string `env:"LOAD_WORKERS" env-default:"4"`
+	LoadDuration            string `env:"LOAD_DURATION" env-default:"60"`  // 0 - без ограничения
+	LoadOperations          string `env:"LOAD_OPERATIONS" env-default:"0"` // 0 - без ограничения
+	LoadRate                string `env:"LOAD_RATE" env-default:"0"`       // операций в секунду, 0 - без ограничения
+	Workload                string `env:"WORKLOAD"`                        // Формат: "get=70,put=20,head=5,list=5"
+	WorkloadObjects         string `env:"WORKLOAD_OBJECTS" env-default:"100"`
+	SizeDistribution        string `env:"SIZE_DISTRIBUTION"` // uniform:..., lognormal:..., buckets:...
+	RampWorkers             string `env:"RAMP_WORKERS" env-default:"1,2,4,8,16,32,64"`
+	RampSizes               string `env:"RAMP_SIZES" env-default:"1MiB"` // Формат: "4096,1MiB" (число - в байтах)
+	RampStepDuration        string `env:"RAMP_STEP_DURATION" env-default:"30"`
+	RampTimeout             string `env:"RAMP_TIMEOUT" env-default:"60"`       // Таймаут каждой операции
+	RampPlateau             string `env:"RAMP_PLATEAU" env-default:"5"`        // в процентах прироста пропускной способности
+	RampMaxErrorRate        string `env:"RAMP_MAX_ERROR_RATE" env-default:"1"` // в процентах
+	RampMaxP99              string `env:"RAMP_MAX_P99" env-default:"0"`        // 0 - без ограничения
+	RampOutput              string `env:"RAMP_OUTPUT" env-default:"table"`     // table, csv
 }
 
+// Target описывает проверяемое S3 хранилище: эндпоинт, учетные данные, бакет и набор проб.
+type Target struct {
+	Name        string
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	FilesDir    string
+	Probes      []Probe
+
+	// Источник учетных данных и его параметры
+	CredentialsProvider   string
+	S3AccessKeyFile       string
+	S3SecretKeyFile       string
+	SharedCredentialsFile string
+	Profile               string
+	RoleARN               string
+	RoleSessionName       string
+	ExternalID            string
+	WebIdentityTokenFile  string
+	STSEndpoint           string
+	CredentialProcess     string
+
+	// Адресация бакета и параметры подписи запросов
+	AddressingStyle  string
+	SignatureVersion string
+	PayloadSigning   string
+}
+
+// Источники учетных данных цели.
+const (
+	CredentialsStatic      = "static"
+	CredentialsShared      = "shared"
+	CredentialsWebIdentity = "web_identity"
+	CredentialsAssumeRole  = "assume_role"
+	CredentialsProcess     = "process"
+)
+
+// Стили адресации бакета.
+const (
+	AddressingPath    = "path"
+	AddressingVirtual = "virtual"
+)
+
+// Версии подписи запросов.
+const (
+	SignatureV4 = "v4"
+	SignatureV2 = "v2"
+)
+
+// Способы подписи тела запроса (только для Signature Version 4).
+const (
+	PayloadSigned    = "signed"
+	PayloadUnsigned  = "unsigned"
+	PayloadStreaming = "streaming"
+)
+
+// Probe описывает проверяемый файл и таймауты операций с ним.
+type Probe struct {
+	FileName        string
+	Key             string // Ключ объекта в бакете, если отличается от FileName
+	TempFile        string // Если пуст, содержимое объекта генерируется по PayloadSeed
+	PayloadSeed     uint64
+	FileSizeBytes   int
+	UploadTimeout   time.Duration
+	DownloadTimeout time.Duration
+	DeleteTimeout   time.Duration
+
+	// Политика повторов запросов SDK
+	MaxRetries     int
+	RetryMinDelay  time.Duration
+	RetryMaxDelay  time.Duration
+	RetryableCodes []string
+
+	// Расписание запусков: интервал или cron выражение и случайная задержка запуска
+	Interval time.Duration
+	Cron     string
+	Jitter   time.Duration
+
+	Scenario []string          // Операции итерации, по умолчанию upload, download, verify, delete
+	Labels   map[string]string // Произвольные метки пробы в /status и метрике s3_probe_label
+}
+
+// ObjectKey возвращает ключ объекта пробы в бакете.
+func (p *Probe) ObjectKey() string {
+	if p.Key != "" {
+		return p.Key
+	}
+	return p.FileName
+}
+
+// DownloadPath возвращает путь, по которому скачивается объект пробы в каталоге filesDir.
+func (p *Probe) DownloadPath(filesDir string) string {
+	return filepath.Join(filesDir, p.ObjectKey()+"-tmp")
+}
+
+// ScenarioTimeout возвращает максимальную длительность сценария пробы - сумму таймаутов ее операций.
+func (p *Probe) ScenarioTimeout() time.Duration {
+	return p.UploadTimeout + p.DownloadTimeout + p.DeleteTimeout
+}
+
+// Значения политики повторов по умолчанию совпадают с client.DefaultRetryer AWS SDK.
+const (
+	DefaultMaxRetries    = 3
+	DefaultRetryMinDelay = 30 * time.Millisecond
+	DefaultRetryMaxDelay = 300 * time.Second
+)
+
+// defaultTargetName - имя цели, если список TARGETS не задан.
+const defaultTargetName = "default"
+
 type Config struct {
 	AwsLogLevel             aws.LogLevelType
-	TempFiles               []string
 	Logger                  *slog.Logger
+	LogLevel                slog.Level
 	FilesDir                string
-	FileNames               []string
-	FileSizesBytes          []int
-	UploadTimeoutSecs       []int
-	DownloadTimeoutSecs     []int
-	DeleteTimeoutSecs       []int
-	S3Endpoint              string
-	S3Region                string
-	S3AccessKey             string
-	S3SecretKey             string
-	S3Bucket                string
+	Targets                 []Target
 	MinFileSizeForMultipart int
-	TaskInterval            int
+	TaskInterval            time.Duration
 	ConcurrencyMPU          int
 	Profiler                bool
+	ReadinessMode           string
+	ReadinessCacheTTL       time.Duration
+	ReadinessTimeout        time.Duration
+	ReadinessRuns           int
+	LivenessMultiplier      int
+	StatusHistory           int
+	ShutdownTimeout         time.Duration
+	Mode                    string
+	OnceReport              string
+	Cleanup                 CleanupOptions
+	APIToken                string
+	ProbesStateFile         string
+	ConfigFile              string
+	ReloadInterval          time.Duration
+	Load                    LoadOptions
+	Ramp                    RampOptions
+
+	logLevel *slog.LevelVar
+}
+
+// LoadOptions задает параметры нагрузочного режима.
+type LoadOptions struct {
+	Workers    int
+	Duration   time.Duration
+	Operations int
+	Rate       float64
+
+	// Смешанная нагрузка: веса операций и число заранее загруженных объектов на каждой цели
+	Workload        []OperationWeight
+	WorkloadObjects int
+
+	// Распределение размеров объектов; если не задано, используются размеры проб
+	Sizes *SizeDistribution
+}
+
+// OperationWeight - доля операции в смешанной нагрузке.
+type OperationWeight struct {
+	Operation string
+	Weight    int
 }
 
+// Операции смешанной нагрузки.
+const (
+	OperationGet  = "get"
+	OperationPut  = "put"
+	OperationHead = "head"
+	OperationList = "list"
+)
+
+// Операции сценария пробы, кроме get, head и list.
+const (
+	OperationUpload   = "upload"
+	OperationDownload = "download"
+	OperationVerify   = "verify"
+	OperationDelete   = "delete"
+)
+
+// ValidateScenario проверяет, что сценарий состоит из известных операций, а verify следует за download.
+func ValidateScenario(operations []string) error {
+	downloaded := false
+	for _, operation := range operations {
+		switch operation {
+		case OperationUpload, OperationDelete, OperationGet, OperationHead, OperationList:
+		case OperationDownload:
+			downloaded = true
+		case OperationVerify:
+			if !downloaded {
+				return fmt.Errorf("%s requires a preceding %s", OperationVerify, OperationDownload)
+			}
+		default:
+			return fmt.Errorf("unknown operation %q", operation)
+		}
+	}
+	return nil
+}
+
+// RampOptions задает параметры поиска точки насыщения: ступени числа воркеров и размеры объектов,
+// длительность ступени и условия остановки.
+type RampOptions struct {
+	Workers      []int
+	Sizes        []int
+	StepDuration time.Duration
+	Timeout      time.Duration
+	Plateau      float64
+	MaxErrorRate float64
+	MaxP99       time.Duration
+	Output       string
+}
+
+// CleanupOptions задает параметры очистки объектов, оставшихся после прерванных сценариев.
+type CleanupOptions struct {
+	Prefixes []string // Если пуст, используются ключи проб и объектов нагрузочных режимов
+	MinAge   time.Duration
+	DryRun   bool
+	Interval time.Duration
+}
+
+// Режимы работы приложения.
+const (
+	ModeDaemon = "daemon"
+	ModeOnce   = "once"
+	ModeLoad   = "load"
+	ModeRamp   = "ramp"
+)
+
+// Форматы отчета режима ramp.
+const (
+	RampOutputTable = "table"
+	RampOutputCSV   = "csv"
+)
+
+// Режимы проверки готовности.
+const (
+	ReadinessListBuckets = "list_buckets"
+	ReadinessHeadBucket  = "head_bucket"
+	ReadinessProbes      = "probes"
+)
+
+// MustLoad загружает конфигурацию, как Load, но при ошибке записывает в лог все неверные параметры
+// и завершает процесс. Локальные файлы проб создает CreateTempFiles.
 func MustLoad() *Config {
-	var env EnvData
-	err := cleanenv.ReadEnv(&env)
+	cfg, err := Load()
 	if err != nil {
-		log.Fatal("cannot parse config from env", err)
+		logger := initLogger(os.Getenv("LOG_FORMAT"), os.Getenv("MODE"), new(slog.LevelVar))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			logger.Error("Invalid configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+		for _, fieldErr := range validationErr.Errors {
+			logger.Error("Invalid configuration", slog.Any("error", fieldErr))
+		}
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// Load читает конфигурацию из CONFIG_FILE, если он задан, и переменных окружения и проверяет ее.
+// Локальные файлы проб не создаются.
+// Ошибка *ValidationError перечисляет все неверные параметры.
+func Load() (*Config, error) {
+	var errs problems
+	var specs []ProbeSpec
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			errs.add("CONFIG_FILE", err)
+			return nil, errs.err()
+		}
+		applyConfigEnv(file.Env)
+		specs = file.Probes
+	}
+	var env EnvData
+	if err := cleanenv.ReadEnv(&env); err != nil {
+		errs.add("", fmt.Errorf("cannot parse config from env: %w", err))
+		return nil, errs.err()
 	}
+	var err error
 	var cfg Config
+	// Разобранные значения проверяются сразу, ошибки накапливаются в errs
+	duration := func(setting, input string, unit time.Duration, allowZero bool) time.Duration {
+		value, err := parseDurationSetting(input, unit, allowZero)
+		if err != nil {
+			errs.add(setting, err)
+		}
+		return value
+	}
+	// integer разбирает целое значение параметра не меньше min (0 или 1)
+	integer := func(setting, input string, min int) int {
+		value, err := strconv.Atoi(strings.TrimSpace(input))
+		switch {
+		case err != nil:
+			errs.addf(setting, "invalid integer %q", input)
+		case value < min && min == 1:
+			errs.addf(setting, "must be positive, got %d", value)
+		case value < min:
+			errs.addf(setting, "must not be negative, got %d", value)
+		}
+		return value
+	}
+	number := func(setting, input string) float64 {
+		value, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		switch {
+		case err != nil:
+			errs.addf(setting, "invalid number %q", input)
+		case value < 0:
+			errs.addf(setting, "must not be negative, got %s", strings.TrimSpace(input))
+		}
+		return value
+	}
+	boolean := func(setting, input string) bool {
+		value, err := strconv.ParseBool(strings.TrimSpace(input))
+		if err != nil {
+			errs.addf(setting, "invalid boolean %q, expected true or false", input)
+		}
+		return value
+	}
 	cfg.FilesDir = env.FilesDir
-	cfg.S3Endpoint = env.S3Endpoint
-	cfg.S3Bucket = env.S3Bucket
-	cfg.S3Region = env.S3Region
-	cfg.S3AccessKey = env.S3AccessKey
-	cfg.S3SecretKey = env.S3SecretKey
-	cfg.MinFileSizeForMultipart = env.MinFileSizeForMultipart
-	cfg.TaskInterval = env.TaskInterval
-	cfg.Profiler = env.Profiler
-	cfg.ConcurrencyMPU = env.ConcurrencyMPU
+	if cfg.MinFileSizeForMultipart, err = parseSizeSetting(env.MinFileSizeForMultipart); err == nil && cfg.MinFileSizeForMultipart == 0 {
+		err = errors.New("must be positive")
+	}
+	if err != nil {
+		errs.add("MIN_FILE_SIZE_FOR_MULTIPART", err)
+	}
+	cfg.TaskInterval = duration("TASK_INTERVAL", env.TaskInterval, time.Second, false)
+	cfg.Profiler = boolean("PROFILER", env.Profiler)
+	cfg.ConcurrencyMPU = integer("CONCURRENCY_MPU", env.ConcurrencyMPU, 1)
+	cfg.ReadinessMode = env.ReadinessMode
+	cfg.ReadinessCacheTTL = duration("READINESS_CACHE_TTL", env.ReadinessCacheTTL, time.Second, true)
+	cfg.ReadinessTimeout = duration("READINESS_TIMEOUT", env.ReadinessTimeout, time.Second, false)
+	cfg.ReadinessRuns = integer("READINESS_RUNS", env.ReadinessRuns, 1)
+	cfg.LivenessMultiplier = integer("LIVENESS_MULTIPLIER", env.LivenessMultiplier, 1)
+	cfg.StatusHistory = integer("STATUS_HISTORY", env.StatusHistory, 1)
+	cfg.ShutdownTimeout = duration("SHUTDOWN_TIMEOUT", env.ShutdownTimeout, time.Second, true)
+	cfg.Mode = env.Mode
+	cfg.OnceReport = env.OnceReport
+	cfg.APIToken = env.APIToken
+	cfg.ProbesStateFile = env.ProbesStateFile
+	cfg.ConfigFile = env.ConfigFile
+	cfg.ReloadInterval = duration("CONFIG_RELOAD_INTERVAL", env.ReloadInterval, time.Second, true)
+	cfg.Cleanup = CleanupOptions{
+		MinAge:   duration("CLEANUP_MIN_AGE", env.CleanupMinAge, time.Second, true),
+		DryRun:   boolean("CLEANUP_DRY_RUN", env.CleanupDryRun),
+		Interval: duration("CLEANUP_INTERVAL", env.CleanupInterval, time.Second, true),
+	}
+	for _, prefix := range strings.Split(env.CleanupPrefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			cfg.Cleanup.Prefixes = append(cfg.Cleanup.Prefixes, prefix)
+		}
+	}
+	cfg.Load = LoadOptions{
+		Workers:    integer("LOAD_WORKERS", env.LoadWorkers, 1),
+		Duration:   duration("LOAD_DURATION", env.LoadDuration, time.Second, true),
+		Operations: integer("LOAD_OPERATIONS", env.LoadOperations, 0),
+		Rate:       number("LOAD_RATE", env.LoadRate),
+	}
 	if env.LogLevel == "debug" {
 		cfg.AwsLogLevel = aws.LogDebug
 	} else {
 		cfg.AwsLogLevel = aws.LogOff
 	}
 
-	cfg.Logger = initLogger(env.LogLevel, env.LogFormat)
+	cfg.LogLevel = parseLogLevel(env.LogLevel)
+	cfg.logLevel = new(slog.LevelVar)
+	cfg.logLevel.Set(cfg.LogLevel)
+	cfg.Logger = initLogger(env.LogFormat, cfg.Mode, cfg.logLevel)
 	cfg.Logger.Debug("log format - " + env.LogFormat)
-	cfg.Logger.Debug("FilePatterns - " + env.FilePatterns)
-	cfg.FileNames = cfg.parseCSV(env.FilePatterns)
-	cfg.Logger.Debug("FileSizes - " + env.FileSizes)
-	cfg.FileSizesBytes = cfg.parseIntCSV(env.FileSizes)
-	cfg.Logger.Debug("UploadTimeouts - " + env.UploadTimeouts)
-	cfg.UploadTimeoutSecs = cfg.parseIntCSV(env.UploadTimeouts)
-	cfg.Logger.Debug("DownloadTimeouts - " + env.DownloadTimeouts)
-	cfg.DownloadTimeoutSecs = cfg.parseIntCSV(env.DownloadTimeouts)
-	cfg.Logger.Debug("DeleteTimeouts - " + env.DeleteTimeouts)
-	cfg.DeleteTimeoutSecs = cfg.parseIntCSV(env.DeleteTimeouts)
-	cfg.Logger.Debug("s3 endpoint - " + env.S3Endpoint)
 	cfg.Logger.Debug("FILES_DIR - " + env.FilesDir)
-	cfg.Logger.Debug("s3 MinFileSizeForMultipart - " + strconv.Itoa(env.MinFileSizeForMultipart))
-	if len(cfg.FileNames) != len(cfg.FileSizesBytes) || len(cfg.FileNames) != len(cfg.UploadTimeoutSecs) || len(cfg.FileNames) != len(cfg.DownloadTimeoutSecs) || len(cfg.FileNames) != len(cfg.DeleteTimeoutSecs) {
-		cfg.Logger.Error("Mismatch in the number of files, sizes, or timeouts specified")
-		os.Exit(1)
+	cfg.Logger.Debug("s3 MinFileSizeForMultipart - " + strconv.Itoa(cfg.MinFileSizeForMultipart))
+
+	switch cfg.ReadinessMode {
+	case ReadinessListBuckets, ReadinessHeadBucket, ReadinessProbes:
+	default:
+		errs.addf("READINESS_MODE", "unknown readiness mode %q", cfg.ReadinessMode)
+	}
+	switch cfg.Mode {
+	case ModeDaemon, ModeOnce:
+	case ModeLoad:
+		if cfg.Load.Duration <= 0 && cfg.Load.Operations <= 0 {
+			errs.addf("LOAD_DURATION, LOAD_OPERATIONS", "either must be set")
+		}
+		if strings.TrimSpace(env.SizeDistribution) != "" {
+			if cfg.Load.Sizes, err = ParseSizeDistribution(env.SizeDistribution); err != nil {
+				errs.add("SIZE_DISTRIBUTION", err)
+			}
+		}
+		if strings.TrimSpace(env.Workload) != "" {
+			if cfg.Load.Workload, err = parseWorkload(env.Workload); err != nil {
+				errs.add("WORKLOAD", err)
+			}
+			cfg.Load.WorkloadObjects = integer("WORKLOAD_OBJECTS", env.WorkloadObjects, 1)
+		}
+	case ModeRamp:
+		cfg.Ramp = RampOptions{
+			StepDuration: duration("RAMP_STEP_DURATION", env.RampStepDuration, time.Second, false),
+			Timeout:      duration("RAMP_TIMEOUT", env.RampTimeout, time.Second, false),
+			Plateau:      number("RAMP_PLATEAU", env.RampPlateau) / 100,
+			MaxErrorRate: number("RAMP_MAX_ERROR_RATE", env.RampMaxErrorRate) / 100,
+			MaxP99:       duration("RAMP_MAX_P99", env.RampMaxP99, time.Second, true),
+			Output:       env.RampOutput,
+		}
+		if cfg.Ramp.Workers, err = parseIntCSV(env.RampWorkers); err != nil {
+			errs.add("RAMP_WORKERS", err)
+		}
+		for i, workers := range cfg.Ramp.Workers {
+			switch {
+			case workers < 1:
+				errs.addf("RAMP_WORKERS", "must be positive, got %d", workers)
+			case i > 0 && workers <= cfg.Ramp.Workers[i-1]:
+				errs.addf("RAMP_WORKERS", "must be ascending, got %d after %d", workers, cfg.Ramp.Workers[i-1])
+			}
+		}
+		for _, input := range parseCSV(env.RampSizes) {
+			size, err := parseSizeSetting(input)
+			if err != nil {
+				errs.add("RAMP_SIZES", err)
+			}
+			cfg.Ramp.Sizes = append(cfg.Ramp.Sizes, size)
+		}
+		if cfg.Ramp.Output != RampOutputTable && cfg.Ramp.Output != RampOutputCSV {
+			errs.addf("RAMP_OUTPUT", "unknown format %q", cfg.Ramp.Output)
+		}
+	default:
+		errs.addf("MODE", "unknown mode %q", cfg.Mode)
+	}
+
+	targetNames := []string{defaultTargetName}
+	if strings.TrimSpace(env.Targets) != "" {
+		targetNames = parseCSV(env.Targets)
+	}
+	seen := make(map[string]bool, len(targetNames))
+	for _, name := range targetNames {
+		seen[strings.TrimSpace(name)] = false
+	}
+	// Пробы файла проверяются один раз, а не для каждой цели; неверные пробы пропускаются
+	fileProbes := make([]*Probe, len(specs))
+	for i, spec := range specs {
+		if _, ok := seen[spec.Target]; spec.Target != "" && !ok {
+			errs.addf(fmt.Sprintf("probes[%d].target", i), "unknown target %q", spec.Target)
+		}
+		if probe, ok := probeFromSpec(i, spec, cfg.TaskInterval, &errs); ok {
+			fileProbes[i] = &probe
+		}
+	}
+	for _, name := range targetNames {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			errs.addf("TARGETS", "empty or duplicate target name %q", name)
+			continue
+		}
+		seen[name] = true
+		cfg.Targets = append(cfg.Targets, cfg.loadTarget(name, &env, specs, fileProbes, &errs))
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadTarget собирает конфигурацию цели и добавляет найденные ошибки в errs. Для именованных целей значения
+// берутся из переменных окружения с префиксом имени цели (например, SITE1_S3_BUCKET), а при их отсутствии -
+// из общих переменных. Если заданы описания проб specs из файла конфигурации, пробы берутся из fileProbes.
+func (cfg *Config) loadTarget(name string, env *EnvData, specs []ProbeSpec, fileProbes []*Probe, errs *problems) Target {
+	prefix := ""
+	filesDir := cfg.FilesDir
+	if name != defaultTargetName {
+		prefix = targetEnvPrefix(name)
+		filesDir = filepath.Join(cfg.FilesDir, name)
+	}
+	lookup := func(key, fallback string) string {
+		if prefix == "" {
+			return fallback
+		}
+		if value, ok := os.LookupEnv(prefix + key); ok {
+			return value
+		}
+		return fallback
+	}
+	// fail сообщает об ошибке в переменной, из которой фактически взято значение
+	fail := func(key, format string, args ...any) {
+		fieldErr := FieldError{Field: key, Message: fmt.Sprintf(format, args...)}
+		if prefix != "" {
+			fieldErr.Target = name
+			if _, ok := os.LookupEnv(prefix + key); ok {
+				fieldErr.Field = prefix + key
+			}
+		}
+		errs.append(fieldErr)
+	}
+
+	target := Target{
+		Name:        name,
+		S3Endpoint:  lookup("S3_ENDPOINT", env.S3Endpoint),
+		S3Region:    lookup("S3_REGION", env.S3Region),
+		S3AccessKey: lookup("S3_ACCESS_KEY", env.S3AccessKey),
+		S3SecretKey: lookup("S3_SECRET_KEY", env.S3SecretKey),
+		S3Bucket:    lookup("S3_BUCKET", env.S3Bucket),
+		FilesDir:    filesDir,
+
+		CredentialsProvider:   lookup("S3_CREDENTIALS_PROVIDER", env.CredentialsProvider),
+		S3AccessKeyFile:       lookup("S3_ACCESS_KEY_FILE", env.S3AccessKeyFile),
+		S3SecretKeyFile:       lookup("S3_SECRET_KEY_FILE", env.S3SecretKeyFile),
+		SharedCredentialsFile: lookup("S3_SHARED_CREDENTIALS_FILE", env.SharedCredentialsFile),
+		Profile:               lookup("S3_PROFILE", env.Profile),
+		RoleARN:               lookup("S3_ROLE_ARN", env.RoleARN),
+		RoleSessionName:       lookup("S3_ROLE_SESSION_NAME", env.RoleSessionName),
+		ExternalID:            lookup("S3_EXTERNAL_ID", env.ExternalID),
+		WebIdentityTokenFile:  lookup("S3_WEB_IDENTITY_TOKEN_FILE", env.WebIdentityTokenFile),
+		STSEndpoint:           lookup("S3_STS_ENDPOINT", env.STSEndpoint),
+		CredentialProcess:     lookup("S3_CREDENTIAL_PROCESS", env.CredentialProcess),
+
+		AddressingStyle:  lookup("S3_ADDRESSING_STYLE", env.AddressingStyle),
+		SignatureVersion: lookup("S3_SIGNATURE_VERSION", env.SignatureVersion),
+		PayloadSigning:   lookup("S3_PAYLOAD_SIGNING", env.PayloadSigning),
+	}
+	if strings.TrimSpace(target.S3Endpoint) == "" {
+		fail("S3_ENDPOINT", "must be set")
+	}
+	if strings.TrimSpace(target.S3Bucket) == "" {
+		fail("S3_BUCKET", "must be set")
+	}
+	switch target.CredentialsProvider {
+	case CredentialsStatic, CredentialsShared:
+	case CredentialsAssumeRole:
+		if target.RoleARN == "" {
+			fail("S3_ROLE_ARN", "required for credentials provider %s", target.CredentialsProvider)
+		}
+	case CredentialsWebIdentity:
+		if target.RoleARN == "" {
+			fail("S3_ROLE_ARN", "required for credentials provider %s", target.CredentialsProvider)
+		}
+		if target.WebIdentityTokenFile == "" {
+			fail("S3_WEB_IDENTITY_TOKEN_FILE", "required for credentials provider %s", target.CredentialsProvider)
+		}
+	case CredentialsProcess:
+		if target.CredentialProcess == "" {
+			fail("S3_CREDENTIAL_PROCESS", "required for credentials provider %s", target.CredentialsProvider)
+		}
+	default:
+		fail("S3_CREDENTIALS_PROVIDER", "unknown credentials provider %q", target.CredentialsProvider)
+	}
+	checkSigningOptions(&target, fail)
+	if len(specs) > 0 {
+		seen := make(map[string]bool)
+		invalid := false
+		for i, spec := range specs {
+			if spec.Target != "" && spec.Target != name {
+				continue
+			}
+			if fileProbes[i] == nil {
+				invalid = true
+				continue
+			}
+			if seen[spec.Name] {
+				errs.addf(fmt.Sprintf("probes[%d].name", i), "duplicate probe name %q", spec.Name)
+				continue
+			}
+			seen[spec.Name] = true
+			target.Probes = append(target.Probes, *fileProbes[i])
+		}
+		// Без проб цель не проверяется, а нагрузочные режимы не могут выбрать для нее объекты
+		if len(target.Probes) == 0 && !invalid {
+			errs.append(FieldError{Target: name, Field: "probes", Message: "no probes for the target, set target of a probe or leave it empty"})
+		}
+		cfg.Logger.Debug("target - "+name, slog.String("s3 endpoint", target.S3Endpoint), slog.String("bucket", target.S3Bucket), slog.Int("probes", len(target.Probes)))
+		return target
+	}
+
+	filePatterns := lookup("FILE_PATTERNS", env.FilePatterns)
+	fileSizes := lookup("FILE_SIZES", env.FileSizes)
+	uploadTimeouts := lookup("UPLOAD_TIMEOUTS", env.UploadTimeouts)
+	downloadTimeouts := lookup("DOWNLOAD_TIMEOUTS", env.DownloadTimeouts)
+	deleteTimeouts := lookup("DELETE_TIMEOUTS", env.DeleteTimeouts)
+	maxRetries := lookup("MAX_RETRIES", env.MaxRetries)
+	retryMinDelays := lookup("RETRY_MIN_DELAYS", env.RetryMinDelays)
+	retryMaxDelays := lookup("RETRY_MAX_DELAYS", env.RetryMaxDelays)
+	retryableCodes := lookup("RETRYABLE_CODES", env.RetryableCodes)
+	schedules := lookup("SCHEDULES", env.Schedules)
+	scheduleJitters := lookup("SCHEDULE_JITTERS", env.ScheduleJitters)
+	cfg.Logger.Debug("target - "+name, slog.String("s3 endpoint", target.S3Endpoint), slog.String("bucket", target.S3Bucket))
+	cfg.Logger.Debug("FilePatterns - " + filePatterns)
+	cfg.Logger.Debug("FileSizes - " + fileSizes)
+	cfg.Logger.Debug("UploadTimeouts - " + uploadTimeouts)
+	cfg.Logger.Debug("DownloadTimeouts - " + downloadTimeouts)
+	cfg.Logger.Debug("DeleteTimeouts - " + deleteTimeouts)
+	cfg.Logger.Debug("MaxRetries - " + maxRetries)
+	cfg.Logger.Debug("RetryableCodes - " + retryableCodes)
+	cfg.Logger.Debug("Schedules - " + schedules)
+
+	fileNames := parseCSV(filePatterns)
+	count := len(fileNames)
+	for _, fileName := range fileNames {
+		if fileName == "" || strings.Contains(fileName, "/") {
+			fail("FILE_PATTERNS", "invalid file name %q", fileName)
+		}
+	}
+	// perProbe разбивает список значений по пробам. Обязательный список содержит значение для каждого файла,
+	// необязательный может быть пустым (значение по умолчанию) или содержать одно значение для всех проб.
+	// При несовпадении числа значений возвращается nil, и значения по умолчанию не заменяются.
+	perProbe := func(setting, input string, required bool) []string {
+		values := make([]string, count)
+		if strings.TrimSpace(input) == "" && !required {
+			return values
+		}
+		parts := parseCSV(input)
+		switch {
+		case len(parts) == count:
+			copy(values, parts)
+		case len(parts) == 1 && !required:
+			for i := range values {
+				values[i] = parts[0]
+			}
+		default:
+			fail(setting, "mismatch in the number of files in FILE_PATTERNS (%d) and values (%d)", count, len(parts))
+			return nil
+		}
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		return values
+	}
+	fileSizesPerProbe := perProbe("FILE_SIZES", fileSizes, true)
+	uploadTimeoutsPerProbe := perProbe("UPLOAD_TIMEOUTS", uploadTimeouts, true)
+	downloadTimeoutsPerProbe := perProbe("DOWNLOAD_TIMEOUTS", downloadTimeouts, true)
+	deleteTimeoutsPerProbe := perProbe("DELETE_TIMEOUTS", deleteTimeouts, true)
+	maxRetriesPerProbe := perProbe("MAX_RETRIES", maxRetries, false)
+	retryMinDelaysPerProbe := perProbe("RETRY_MIN_DELAYS", retryMinDelays, false)
+	retryMaxDelaysPerProbe := perProbe("RETRY_MAX_DELAYS", retryMaxDelays, false)
+	jittersPerProbe := perProbe("SCHEDULE_JITTERS", scheduleJitters, false)
+	retryableCodesPerProbe := perProbe("RETRYABLE_CODES", retryableCodes, false)
+	schedulesPerProbe := make([]string, count)
+	if strings.TrimSpace(schedules) != "" {
+		entries := strings.Split(schedules, ";")
+		if len(entries) != 1 && len(entries) != count {
+			fail("SCHEDULES", "mismatch in the number of files and per-probe values")
+			entries = entries[:1]
+		}
+		for i := range schedulesPerProbe {
+			schedulesPerProbe[i] = strings.TrimSpace(entries[0])
+			if len(entries) == count {
+				schedulesPerProbe[i] = strings.TrimSpace(entries[i])
+			}
+		}
+	}
+	// Значения с ошибками остаются нулевыми или значениями по умолчанию, чтобы проверить остальные параметры
+	duration := func(setting string, values []string, i int, unit time.Duration, allowZero bool, dst *time.Duration) {
+		// Пустое значение необязательного списка (они допускают ноль) означает значение по умолчанию
+		if values == nil || values[i] == "" && allowZero {
+			return
+		}
+		value, err := parseDurationSetting(values[i], unit, allowZero)
+		if err != nil {
+			fail(setting, "probe %s: %s", fileNames[i], err)
+		}
+		*dst = value
+	}
+	for i, fileName := range fileNames {
+		target.Probes = append(target.Probes, Probe{
+			FileName:      fileName,
+			MaxRetries:    DefaultMaxRetries,
+			RetryMinDelay: DefaultRetryMinDelay,
+			RetryMaxDelay: DefaultRetryMaxDelay,
+		})
+		probe := &target.Probes[i]
+		var err error
+		if fileSizesPerProbe != nil {
+			if probe.FileSizeBytes, err = parseSizeSetting(fileSizesPerProbe[i]); err != nil {
+				fail("FILE_SIZES", "probe %s: %s", fileName, err)
+			}
+		}
+		duration("UPLOAD_TIMEOUTS", uploadTimeoutsPerProbe, i, time.Second, false, &probe.UploadTimeout)
+		duration("DOWNLOAD_TIMEOUTS", downloadTimeoutsPerProbe, i, time.Second, false, &probe.DownloadTimeout)
+		duration("DELETE_TIMEOUTS", deleteTimeoutsPerProbe, i, time.Second, false, &probe.DeleteTimeout)
+		duration("RETRY_MIN_DELAYS", retryMinDelaysPerProbe, i, time.Millisecond, true, &probe.RetryMinDelay)
+		duration("RETRY_MAX_DELAYS", retryMaxDelaysPerProbe, i, time.Millisecond, true, &probe.RetryMaxDelay)
+		duration("SCHEDULE_JITTERS", jittersPerProbe, i, time.Second, true, &probe.Jitter)
+		if maxRetriesPerProbe != nil && maxRetriesPerProbe[i] != "" {
+			value, err := strconv.Atoi(maxRetriesPerProbe[i])
+			if err != nil || value < 0 {
+				fail("MAX_RETRIES", "probe %s: must be a non-negative integer, got %q", fileName, maxRetriesPerProbe[i])
+			}
+			probe.MaxRetries = value
+		}
+		if retryableCodesPerProbe != nil {
+			for _, code := range strings.Split(retryableCodesPerProbe[i], "|") {
+				if code = strings.TrimSpace(code); code != "" {
+					probe.RetryableCodes = append(probe.RetryableCodes, code)
+				}
+			}
+		}
+		probe.Interval, probe.Cron, err = ParseSchedule(schedulesPerProbe[i], cfg.TaskInterval)
+		// Неверный TASK_INTERVAL уже отмечен, для проб без расписания ошибка не повторяется
+		if err != nil && (schedulesPerProbe[i] != "" || cfg.TaskInterval > 0) {
+			fail("SCHEDULES", "probe %s: %s", fileName, err)
+		}
+	}
+	return target
+}
+
+// checkSigningOptions проверяет стиль адресации и параметры подписи цели.
+func checkSigningOptions(target *Target, fail func(key, format string, args ...any)) {
+	invalid := func(setting, value string) {
+		fail(setting, "invalid signing option %q", value)
+	}
+	if target.AddressingStyle != AddressingPath && target.AddressingStyle != AddressingVirtual {
+		invalid("S3_ADDRESSING_STYLE", target.AddressingStyle)
+	}
+	switch target.SignatureVersion {
+	case SignatureV4, SignatureV2:
+	default:
+		invalid("S3_SIGNATURE_VERSION", target.SignatureVersion)
+		return
+	}
+	switch target.PayloadSigning {
+	case PayloadSigned:
+	case PayloadUnsigned, PayloadStreaming:
+		// В Signature Version 2 тело запроса не подписывается
+		if target.SignatureVersion == SignatureV2 {
+			invalid("S3_PAYLOAD_SIGNING", target.PayloadSigning)
+		}
+	default:
+		invalid("S3_PAYLOAD_SIGNING", target.PayloadSigning)
 	}
-	cfg.checkAndRemoveExistingFiles()
-	cfg.createTempFiles()
-	cfg.setupGracefulShutdown()
-	return &cfg
 }
 
-func initLogger(logLevel, logFormat string) *slog.Logger {
-	var level slog.Level
+// targetEnvPrefix возвращает префикс переменных окружения цели: "site-1" -> "SITE_1_".
+func targetEnvPrefix(name string) string {
+	prefix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.ToUpper(prefix) + "_"
+}
+
+func parseLogLevel(logLevel string) slog.Level {
 	switch logLevel {
 	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
+		return slog.LevelDebug
 	case "warn":
-		level = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		level = slog.LevelError
+		return slog.LevelError
 	default:
-		level = slog.LevelInfo
+		return slog.LevelInfo
 	}
+}
 
+// initLogger создает логгер, уровень которого можно изменить во время работы через level.
+// В режиме once логи пишутся в stderr, чтобы stdout содержал только JSON отчет.
+func initLogger(logFormat, mode string, level *slog.LevelVar) *slog.Logger {
+	out := os.Stdout
+	if mode == ModeOnce {
+		out = os.Stderr
+	}
 	var handler slog.Handler
-	if logFormat == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level, AddSource: true})
+	if logFormat == "json" || logFormat == "" {
+		handler = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level, AddSource: true})
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level, AddSource: true})
+		handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level, AddSource: true})
 	}
 	return slog.New(handler)
 }
 
-func (cfg *Config) parseCSV(input string) []string {
+// SetLogLevel изменяет уровень логирования Logger во время работы.
+func (cfg *Config) SetLogLevel(level slog.Level) {
+	cfg.logLevel.Set(level)
+}
+
+func parseCSV(input string) []string {
 	return strings.Split(strings.TrimSpace(input), ",")
 }
 
-func (cfg *Config) parseIntCSV(input string) []int {
-	parts := cfg.parseCSV(input)
+func parseIntCSV(input string) ([]int, error) {
+	parts := parseCSV(input)
 	ints := make([]int, len(parts))
 	for i, part := range parts {
 		num, err := strconv.Atoi(part)
 		if err != nil {
-			cfg.Logger.Error("Invalid integer value in CSV", slog.String("value", part), slog.Any("error", err))
-			os.Exit(1)
+			return nil, fmt.Errorf("invalid integer value %q", part)
 		}
 		ints[i] = num
 	}
-	return ints
+	return ints, nil
 }
 
-func (cfg *Config) checkAndRemoveExistingFiles() {
-	for _, fileName := range cfg.FileNames {
-		matchedFiles, err := filepath.Glob(filepath.Join(cfg.FilesDir, fmt.Sprintf("%s-*", fileName)))
-		if err != nil {
-			cfg.Logger.Warn("Error while checking for existing files", slog.String("file", fileName), slog.Any("error", err))
-			continue
+// parseWorkload разбирает веса операций смешанной нагрузки в формате "get=70,put=20".
+func parseWorkload(input string) ([]OperationWeight, error) {
+	var weights []OperationWeight
+	total := 0
+	for _, entry := range parseCSV(input) {
+		operation, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid workload weight %q", entry)
+		}
+		switch operation = strings.ToLower(strings.TrimSpace(operation)); operation {
+		case OperationGet, OperationPut, OperationHead, OperationList:
+		default:
+			return nil, fmt.Errorf("unknown workload operation %q", operation)
+		}
+		weights = append(weights, OperationWeight{Operation: operation, Weight: weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("workload weights must not all be zero")
+	}
+	return weights, nil
+}
+
+// ParseSchedule разбирает расписание пробы: интервал (длительность или число секунд) или cron выражение.
+// Пустое расписание означает интервал defaultInterval.
+func ParseSchedule(input string, defaultInterval time.Duration) (interval time.Duration, cronSpec string, err error) {
+	if input == "" {
+		if defaultInterval <= 0 {
+			return 0, "", errors.New("schedule interval must be positive")
 		}
-		for _, filePath := range matchedFiles {
-			err = os.Remove(filePath)
+		return defaultInterval, "", nil
+	}
+	if interval, err := ParseDuration(input, time.Second); err == nil {
+		if interval <= 0 {
+			return 0, "", errors.New("schedule interval must be positive")
+		}
+		return interval, "", nil
+	}
+	if _, err := cron.ParseStandard(input); err != nil {
+		return 0, "", fmt.Errorf("invalid schedule %q, expected an interval or a cron expression: %w", input, err)
+	}
+	return 0, input, nil
+}
+
+func (cfg *Config) checkAndRemoveExistingFiles() {
+	for _, target := range cfg.Targets {
+		for _, probe := range target.Probes {
+			matchedFiles, err := filepath.Glob(filepath.Join(target.FilesDir, fmt.Sprintf("%s-*", probe.FileName)))
 			if err != nil {
-				cfg.Logger.Warn("Failed to remove existing file", slog.String("file", filePath), slog.Any("error", err))
-			} else {
-				cfg.Logger.Info("Removed existing file", slog.String("file", filePath))
+				cfg.Logger.Warn("Error while checking for existing files", slog.String("target", target.Name), slog.String("file", probe.FileName), slog.Any("error", err))
+				continue
+			}
+			for _, filePath := range matchedFiles {
+				err = os.Remove(filePath)
+				if err != nil {
+					cfg.Logger.Warn("Failed to remove existing file", slog.String("file", filePath), slog.Any("error", err))
+				} else {
+					cfg.Logger.Info("Removed existing file", slog.String("file", filePath))
+				}
 			}
 		}
 	}
 }
 
-func (cfg *Config) createTempFiles() {
-	for i, fileName := range cfg.FileNames {
-		tempFilePath := cfg.createTempFileWithSize(fileName, cfg.FileSizesBytes[i])
-		cfg.TempFiles = append(cfg.TempFiles, tempFilePath)
-		cfg.Logger.Info("Created temporary file", slog.String("file", tempFilePath), slog.Int("size", cfg.FileSizesBytes[i]))
+// CreateTempFiles удаляет оставшиеся от предыдущего запуска файлы проб и создает локальные файлы проб всех целей.
+// При ошибке уже созданные файлы остаются, их удаляет RemoveTempFiles.
+func (cfg *Config) CreateTempFiles() error {
+	cfg.checkAndRemoveExistingFiles()
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		if err := os.MkdirAll(target.FilesDir, 0o755); err != nil {
+			return fmt.Errorf("create files directory %s: %w", target.FilesDir, err)
+		}
+		for j := range target.Probes {
+			probe := &target.Probes[j]
+			path, err := cfg.CreateTempFileWithSize(target.FilesDir, probe.FileName, probe.FileSizeBytes)
+			if err != nil {
+				return err
+			}
+			probe.TempFile = path
+			cfg.Logger.Info("Created temporary file", slog.String("target", target.Name), slog.String("file", probe.TempFile), slog.Int("size", probe.FileSizeBytes))
+		}
 	}
+	return nil
 }
 
-func (cfg *Config) createTempFileWithSize(fileName string, size int) string {
+// CreateTempFileWithSize создает в каталоге dir файл fileName размером size байт и возвращает путь к нему.
+// Если файл не удалось записать, например при нехватке места, он удаляется.
+func (cfg *Config) CreateTempFileWithSize(dir, fileName string, size int) (string, error) {
 	// Формируем путь к временному файлу
-	tempFilePath := filepath.Join(cfg.FilesDir, fileName)
+	tempFilePath := filepath.Join(dir, fileName)
 
 	// Создаем временный файл
 	tempFile, err := os.Create(tempFilePath)
 	if err != nil {
-		cfg.Logger.Error("Failed to create temporary file", slog.Any("error", err))
-		os.Exit(1)
+		return "", fmt.Errorf("create temporary file: %w", err)
 	}
-	defer func(tempFile *os.File) {
-		err := tempFile.Close()
-		if err != nil {
-			cfg.Logger.Warn("Failed to close temporary file", slog.String("file", tempFilePath), slog.Any("error", err))
-		}
-	}(tempFile)
 
 	// Размер блока записи (например, 4 KB)
 	blockSize := 4 * 1024 // 4 KB
@@ -196,39 +985,41 @@ func (cfg *Config) createTempFileWithSize(fileName string, size int) string {
 	data := make([]byte, blockSize)
 
 	// Записываем данные блоками
-	for written < size {
-		bytesToWrite := blockSize
-		if remaining := size - written; remaining < blockSize {
-			bytesToWrite = remaining
-		}
-
-		n, err := tempFile.Write(data[:bytesToWrite])
-		if err != nil || n != bytesToWrite {
-			cfg.Logger.Error("Failed to write to temporary file", slog.Any("error", err))
-			os.Exit(1)
-		}
-
+	for written < size && err == nil {
+		bytesToWrite := min(blockSize, size-written)
+		var n int
+		n, err = tempFile.Write(data[:bytesToWrite])
 		written += n
 	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if removeErr := os.Remove(tempFilePath); removeErr != nil {
+			cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", tempFilePath), slog.Any("error", removeErr))
+		}
+		return "", fmt.Errorf("write temporary file %s: %w", tempFilePath, err)
+	}
 
 	// Возвращаем путь к созданному файлу
-	return tempFilePath
+	return tempFilePath, nil
 }
 
-func (cfg *Config) setupGracefulShutdown() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		cfg.Logger.Info("Gracefully shutting down...")
-		for _, tempFile := range cfg.TempFiles {
-			err := os.Remove(tempFile)
-			if err != nil {
-				cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", tempFile), slog.Any("error", err))
-			} else {
-				cfg.Logger.Info("Removed temporary file", slog.String("file", tempFile))
+// RemoveTempFiles удаляет локальные файлы проб и файлы, скачанные из S3.
+func (cfg *Config) RemoveTempFiles() {
+	for _, target := range cfg.Targets {
+		for _, probe := range target.Probes {
+			for _, filePath := range []string{probe.TempFile, probe.DownloadPath(target.FilesDir)} {
+				err := os.Remove(filePath)
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", filePath), slog.Any("error", err))
+				} else {
+					cfg.Logger.Info("Removed temporary file", slog.String("file", filePath))
+				}
 			}
 		}
-		os.Exit(0)
-	}()
+	}
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError описывает неверное значение параметра конфигурации.
+type FieldError struct {
+	Target  string // Имя цели, если ошибка относится к одной цели
+	Field   string // Переменная окружения или поле файла конфигурации, например SITE1_S3_BUCKET или probes[0].size
+	Message string
+}
+
+func (e FieldError) Error() string {
+	message := e.Message
+	if e.Field != "" {
+		message = e.Field + ": " + message
+	}
+	if e.Target != "" {
+		message = "target " + e.Target + ": " + message
+	}
+	return message
+}
+
+// ValidationError содержит все ошибки, найденные при загрузке конфигурации.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
+	}
+	if len(messages) == 1 {
+		return messages[0]
+	}
+	return fmt.Sprintf("%d configuration errors: %s", len(messages), strings.Join(messages, "; "))
+}
+
+// problems накапливает ошибки конфигурации, чтобы сообщить обо всех сразу, а не только о первой.
+type problems struct {
+	errors []FieldError
+}
+
+func (p *problems) add(field string, err error) {
+	p.append(FieldError{Field: field, Message: err.Error()})
+}
+
+func (p *problems) addf(field, format string, args ...any) {
+	p.append(FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problems) append(fieldErr FieldError) {
+	// Общие переменные и пробы файла без цели проверяются для каждой цели, одинаковые ошибки выводятся один раз
+	for _, existing := range p.errors {
+		if existing == fieldErr {
+			return
+		}
+	}
+	p.errors = append(p.errors, fieldErr)
+}
+
+func (p *problems) err() error {
+	if len(p.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: p.errors}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+)
+
+// File - содержимое файла конфигурации CONFIG_FILE. Файлы .yaml, .yml, .toml и .json содержат переменные
+// конфигурации и список проб, файлы с другим расширением - только переменные в формате KEY=VALUE.
+type File struct {
+	Env    map[string]string `yaml:"env" toml:"env" json:"env"` // Переменные конфигурации, как в окружении
+	Probes []ProbeSpec       `yaml:"probes" toml:"probes" json:"probes"`
+}
+
+// ProbeSpec описывает пробу в файле конфигурации. Если пробы заданы в файле, FILE_PATTERNS, FILE_SIZES,
+// таймауты, повторы и расписания из переменных окружения не используются.
+type ProbeSpec struct {
+	Name            string            `yaml:"name" toml:"name" json:"name"`
+	Target          string            `yaml:"target" toml:"target" json:"target"`                         // Если пусто, проба выполняется на всех целях
+	Size            Value             `yaml:"size" toml:"size" json:"size"`                               // Число байт или "4KiB", "1.5GB"
+	UploadTimeout   Value             `yaml:"upload_timeout" toml:"upload_timeout" json:"upload_timeout"` // Число секунд или "250ms", "1m30s"
+	DownloadTimeout Value             `yaml:"download_timeout" toml:"download_timeout" json:"download_timeout"`
+	DeleteTimeout   Value             `yaml:"delete_timeout" toml:"delete_timeout" json:"delete_timeout"`
+	Scenario        []string          `yaml:"scenario" toml:"scenario" json:"scenario"` // По умолчанию upload, download, verify, delete
+	Schedule        string            `yaml:"schedule" toml:"schedule" json:"schedule"` // Интервал или cron выражение
+	Jitter          Value             `yaml:"jitter" toml:"jitter" json:"jitter"`       // Число секунд или длительность
+	MaxRetries      *int              `yaml:"max_retries" toml:"max_retries" json:"max_retries"`
+	RetryMinDelay   Value             `yaml:"retry_min_delay" toml:"retry_min_delay" json:"retry_min_delay"` // Число миллисекунд или длительность
+	RetryMaxDelay   Value             `yaml:"retry_max_delay" toml:"retry_max_delay" json:"retry_max_delay"`
+	RetryableCodes  []string          `yaml:"retryable_codes" toml:"retryable_codes" json:"retryable_codes"`
+	Labels          map[string]string `yaml:"labels" toml:"labels" json:"labels"`
+}
+
+// readConfigFile читает файл конфигурации в формате, определяемом расширением.
+func readConfigFile(path string) (*File, error) {
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml", ".json":
+		if err := cleanenv.ReadConfig(path, &file); err != nil {
+			return nil, err
+		}
+	default:
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		file.Env = values
+	}
+	return &file, nil
+}
+
+// configFileVars - переменные окружения, установленные из CONFIG_FILE при предыдущей загрузке.
+var configFileVars []string
+
+// applyConfigEnv устанавливает переменные окружения из файла конфигурации. Переменные окружения процесса,
+// в том числе заданные флагами команд, имеют приоритет над файлом. Переменные, удаленные из файла
+// с предыдущей загрузки, удаляются из окружения.
+func applyConfigEnv(values map[string]string) {
+	for _, key := range configFileVars {
+		os.Unsetenv(key)
+	}
+	configFileVars = nil
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		os.Setenv(key, value)
+		configFileVars = append(configFileVars, key)
+	}
+}
+
+// probeFromSpec создает пробу из описания probes[i] файла конфигурации. Ошибки описания добавляются в errs,
+// в этом случае возвращается false.
+func probeFromSpec(i int, spec ProbeSpec, taskInterval time.Duration, errs *problems) (Probe, bool) {
+	before := len(errs.errors)
+	fail := func(field string, err error) {
+		errs.add(fmt.Sprintf("probes[%d].%s", i, field), err)
+	}
+	probe := Probe{
+		FileName:       spec.Name,
+		MaxRetries:     DefaultMaxRetries,
+		RetryMinDelay:  DefaultRetryMinDelay,
+		RetryMaxDelay:  DefaultRetryMaxDelay,
+		RetryableCodes: spec.RetryableCodes,
+		Scenario:       spec.Scenario,
+		Labels:         spec.Labels,
+	}
+	if spec.Name == "" || strings.Contains(spec.Name, "/") {
+		fail("name", errors.New("must be a non-empty name without '/'"))
+	}
+	var err error
+	if spec.Size != "" {
+		if probe.FileSizeBytes, err = parseSizeSetting(string(spec.Size)); err != nil {
+			fail("size", err)
+		}
+	}
+	for _, field := range []struct {
+		name      string
+		value     Value
+		unit      time.Duration
+		allowZero bool
+		dst       *time.Duration
+	}{
+		{"upload_timeout", spec.UploadTimeout, time.Second, false, &probe.UploadTimeout},
+		{"download_timeout", spec.DownloadTimeout, time.Second, false, &probe.DownloadTimeout},
+		{"delete_timeout", spec.DeleteTimeout, time.Second, false, &probe.DeleteTimeout},
+		{"jitter", spec.Jitter, time.Second, true, &probe.Jitter},
+		{"retry_min_delay", spec.RetryMinDelay, time.Millisecond, true, &probe.RetryMinDelay},
+		{"retry_max_delay", spec.RetryMaxDelay, time.Millisecond, true, &probe.RetryMaxDelay},
+	} {
+		// Незаданные и нулевые необязательные поля сохраняют значения по умолчанию
+		if field.value == "" && field.allowZero {
+			continue
+		}
+		duration, err := parseDurationSetting(string(field.value), field.unit, field.allowZero)
+		if err != nil {
+			fail(field.name, err)
+		} else if duration > 0 {
+			*field.dst = duration
+		}
+	}
+	if spec.MaxRetries != nil {
+		if probe.MaxRetries = *spec.MaxRetries; probe.MaxRetries < 0 {
+			fail("max_retries", fmt.Errorf("must not be negative, got %d", probe.MaxRetries))
+		}
+	}
+	if err := ValidateScenario(spec.Scenario); err != nil {
+		fail("scenario", err)
+	}
+	probe.Interval, probe.Cron, err = ParseSchedule(spec.Schedule, taskInterval)
+	// Неверный TASK_INTERVAL уже отмечен, для проб без расписания ошибка не повторяется
+	if err != nil && (spec.Schedule != "" || taskInterval > 0) {
+		fail("schedule", err)
+	}
+	return probe, len(errs.errors) == before
+}
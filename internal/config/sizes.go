@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Виды распределения размеров объектов.
+const (
+	SizeUniform   = "uniform"
+	SizeLognormal = "lognormal"
+	SizeBuckets   = "buckets"
+)
+
+// SizeDistribution - распределение размеров объектов нагрузки.
+type SizeDistribution struct {
+	Kind string
+
+	// uniform: размер равномерно распределен от Min до Max
+	Min int
+	Max int
+
+	// lognormal: медиана и стандартное отклонение логарифма размера, Max ограничивает размер, если задан
+	Median float64
+	Sigma  float64
+
+	// buckets: размеры с весами
+	Buckets []SizeBucket
+	total   int
+}
+
+// SizeBucket - размер объекта и его вес в распределении.
+type SizeBucket struct {
+	Size   int
+	Weight int
+}
+
+// Sample возвращает случайный размер объекта.
+func (d *SizeDistribution) Sample() int {
+	switch d.Kind {
+	case SizeUniform:
+		return d.Min + rand.IntN(d.Max-d.Min+1)
+	case SizeLognormal:
+		size := int(math.Round(d.Median * math.Exp(d.Sigma*rand.NormFloat64())))
+		if d.Max > 0 {
+			size = min(size, d.Max)
+		}
+		return max(size, 1)
+	default:
+		n := rand.IntN(d.total)
+		for _, bucket := range d.Buckets {
+			if n < bucket.Weight {
+				return bucket.Size
+			}
+			n -= bucket.Weight
+		}
+		return d.Buckets[len(d.Buckets)-1].Size
+	}
+}
+
+// ParseSizeDistribution разбирает распределение размеров в одном из форматов:
+// "uniform:min=4096,max=1048576", "lognormal:median=1048576,sigma=1,max=67108864"
+// или "buckets:60% 4096,30% 1048576,10% 67108864". Размеры можно задавать с единицами: "4KiB", "64MiB".
+func ParseSizeDistribution(input string) (*SizeDistribution, error) {
+	kind, spec, ok := strings.Cut(strings.TrimSpace(input), ":")
+	if !ok {
+		return nil, fmt.Errorf("missing distribution kind in %q", input)
+	}
+	d := &SizeDistribution{Kind: strings.TrimSpace(kind)}
+	switch d.Kind {
+	case SizeUniform, SizeLognormal:
+		params, err := parseParams(spec)
+		if err != nil {
+			return nil, err
+		}
+		if d.Kind == SizeUniform {
+			d.Min, d.Max = int(params["min"]), int(params["max"])
+			if d.Min < 0 || d.Max < d.Min {
+				return nil, fmt.Errorf("uniform distribution requires 0 <= min <= max")
+			}
+		} else {
+			d.Median, d.Sigma, d.Max = params["median"], params["sigma"], int(params["max"])
+			if d.Median <= 0 || d.Sigma < 0 || d.Max < 0 {
+				return nil, fmt.Errorf("lognormal distribution requires positive median and non-negative sigma")
+			}
+		}
+	case SizeBuckets:
+		for _, entry := range strings.Split(spec, ",") {
+			weight, size, ok := strings.Cut(entry, "%")
+			if !ok {
+				return nil, fmt.Errorf("invalid bucket %q, expected \"<weight>%% <size>\"", entry)
+			}
+			bucket := SizeBucket{}
+			var err error
+			if bucket.Weight, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || bucket.Weight < 0 {
+				return nil, fmt.Errorf("invalid bucket weight %q", weight)
+			}
+			if bucket.Size, err = ParseSize(size); err != nil || bucket.Size < 0 {
+				return nil, fmt.Errorf("invalid bucket size %q", size)
+			}
+			d.Buckets = append(d.Buckets, bucket)
+			d.total += bucket.Weight
+		}
+		if d.total == 0 {
+			return nil, fmt.Errorf("bucket weights must not all be zero")
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution kind %q", d.Kind)
+	}
+	return d, nil
+}
+
+// parseParams разбирает параметры распределения в формате "name=value,name=value". Значения, кроме sigma,
+// являются размерами и могут задаваться с единицами.
+func parseParams(spec string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q, expected name=value", entry)
+		}
+		v, err := parseSizeFloat(value)
+		if strings.TrimSpace(name) == "sigma" {
+			v, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of parameter %q: %w", name, err)
+		}
+		params[strings.TrimSpace(name)] = v
+	}
+	return params, nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sizeUnits - множители единиц размера: десятичные (KB = 1000 байт) и двоичные (KiB = 1024 байта).
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// ParseSize разбирает размер: число байт или число с единицей, например "4KiB" или "1.5GB".
+func ParseSize(input string) (int, error) {
+	size, err := parseSizeFloat(input)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(size) > math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", input)
+	}
+	return int(math.Round(size)), nil
+}
+
+func parseSizeFloat(input string) (float64, error) {
+	number, unit := strings.TrimSpace(input), ""
+	if i := strings.IndexFunc(number, unicode.IsLetter); i >= 0 {
+		number, unit = strings.TrimSpace(number[:i]), number[i:]
+	}
+	multiplier, ok := sizeUnits[strings.ToLower(unit)]
+	value, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or a number with unit B, KB, MB, GB, TB, KiB, MiB, GiB, TiB", input)
+	}
+	return value * multiplier, nil
+}
+
+// ParseDuration разбирает длительность: строку Go, например "250ms" или "1m30s", или число в единицах unit,
+// в которых параметр задавался раньше (секундах или миллисекундах).
+func ParseDuration(input string, unit time.Duration) (time.Duration, error) {
+	input = strings.TrimSpace(input)
+	if number, err := strconv.ParseFloat(input, 64); err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) {
+		return time.Duration(number * float64(unit)), nil
+	}
+	duration, err := time.ParseDuration(input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a number of %s or a value like 250ms, 1m30s", input, unitName(unit))
+	}
+	return duration, nil
+}
+
+// FormatDuration возвращает длительность числом в единицах unit, если она делится нацело, иначе строкой Go.
+func FormatDuration(duration, unit time.Duration) string {
+	if duration%unit == 0 {
+		return strconv.FormatInt(int64(duration/unit), 10)
+	}
+	return duration.String()
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Millisecond {
+		return "milliseconds"
+	}
+	return "seconds"
+}
+
+// parseSizeSetting разбирает неотрицательный размер параметра.
+func parseSizeSetting(input string) (int, error) {
+	size, err := ParseSize(input)
+	if err == nil && size < 0 {
+		err = fmt.Errorf("must not be negative, got %s", strings.TrimSpace(input))
+	}
+	return size, err
+}
+
+// parseDurationSetting разбирает длительность параметра: положительную или, если allowZero, неотрицательную.
+func parseDurationSetting(input string, unit time.Duration, allowZero bool) (time.Duration, error) {
+	duration, err := ParseDuration(input, unit)
+	switch {
+	case err != nil:
+	case duration < 0 && allowZero:
+		err = fmt.Errorf("must not be negative, got %s", duration)
+	case duration <= 0 && !allowZero:
+		err = fmt.Errorf("must be positive, got %s", duration)
+	}
+	return duration, err
+}
+
+// Value - размер или длительность в файле конфигурации или API: число в единицах по умолчанию поля
+// или строка с единицами ("4KiB", "250ms"). Значение разбирается при проверке пробы, пустое - не задано.
+type Value string
+
+func (v *Value) UnmarshalText(text []byte) error {
+	*v = Value(text)
+	return nil
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*v = Value(text)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return errors.New("expected a number or a string with units")
+	}
+	*v = Value(number)
+	return nil
+}
+
+// MarshalJSON записывает числа числами, чтобы ответы API и файл состояния оставались совместимыми.
+func (v Value) MarshalJSON() ([]byte, error) {
+	if _, err := strconv.ParseFloat(string(v), 64); err == nil && json.Valid([]byte(v)) {
+		return []byte(v), nil
+	}
+	return json.Marshal(string(v))
+}
@@ -0,0 +1,15 @@
+package dashboard
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Handle отдает страницу с результатами проб. Страница получает данные из /status.
+func Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
@@ -0,0 +1,374 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/s3lib"
+)
+
+// KeyPrefix - префикс ключей объектов проверки записи и чтения.
+const KeyPrefix = "s3syn-test-doctor-"
+
+// Результаты проверки.
+const (
+	StatusPass = "PASS"
+	StatusWarn = "WARN"
+	StatusFail = "FAIL"
+	StatusSkip = "SKIP"
+)
+
+const (
+	// checkTimeout ограничивает сетевые проверки, не связанные с операциями над объектами
+	checkTimeout = 5 * time.Second
+	// certExpiryWarning - срок до истечения сертификата, начиная с которого выдается предупреждение
+	certExpiryWarning = 14 * 24 * time.Hour
+	// Расхождение часов, при котором S3 отклоняет подписанные запросы (RequestTimeTooSkewed), и порог предупреждения
+	maxClockSkew  = 15 * time.Minute
+	warnClockSkew = time.Minute
+	// doctorObjectSize - размер объекта проверки записи и чтения
+	doctorObjectSize = 1024
+)
+
+// Check - результат одной проверки.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// Report - результаты проверок одной цели.
+type Report struct {
+	Target   string  `json:"target"`
+	Endpoint string  `json:"endpoint"`
+	Bucket   string  `json:"bucket"`
+	Checks   []Check `json:"checks"`
+}
+
+// Failed сообщает, есть ли среди проверок неуспешные.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnosis накапливает результаты проверок цели. После неуспешной проверки DNS, TCP или TLS
+// последующие проверки пропускаются.
+type diagnosis struct {
+	report  Report
+	blocked string
+}
+
+func (d *diagnosis) add(name, status, detail, hint string) {
+	d.report.Checks = append(d.report.Checks, Check{Name: name, Status: status, Detail: detail, Hint: hint})
+}
+
+// run выполняет проверку name, если предыдущие сетевые проверки прошли. Неуспешная проверка с block
+// блокирует последующие.
+func (d *diagnosis) run(name string, block bool, check func() (status, detail, hint string)) {
+	if d.blocked != "" {
+		d.add(name, StatusSkip, "requires "+d.blocked, "")
+		return
+	}
+	status, detail, hint := check()
+	d.add(name, status, detail, hint)
+	if block && status == StatusFail {
+		d.blocked = name
+	}
+}
+
+// Diagnose последовательно проверяет все, что нужно для работы с целью: разрешение имени эндпоинта,
+// TCP соединение, TLS рукопожатие и сертификат, расхождение часов, учетные данные, доступ к бакету
+// и права на запись, чтение и удаление объекта.
+func Diagnose(ctx context.Context, cfg *config.Config, target *config.Target) Report {
+	d := &diagnosis{report: Report{Target: target.Name, Endpoint: target.S3Endpoint, Bucket: target.S3Bucket}}
+
+	endpoint, err := endpointURL(target)
+	if err != nil {
+		d.add("endpoint", StatusFail, err.Error(), "S3_ENDPOINT must be an URL like https://s3.example.com")
+		return d.report
+	}
+	host, port := endpoint.Hostname(), endpoint.Port()
+	if port == "" {
+		port = "80"
+		if endpoint.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	d.run("dns", true, func() (string, string, string) {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			hint := "check the endpoint host name and the DNS servers of the pod"
+			if target.AddressingStyle == config.AddressingVirtual {
+				hint = "virtual-hosted addressing needs a DNS record for <bucket>.<endpoint>; check it or set S3_ADDRESSING_STYLE=path"
+			}
+			return StatusFail, err.Error(), hint
+		}
+		return StatusPass, host + " -> " + strings.Join(addrs, ", "), ""
+	})
+
+	d.run("tcp", true, func() (string, string, string) {
+		started := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), checkTimeout)
+		if err != nil {
+			return StatusFail, err.Error(), "check firewalls, network policies, proxies and that the endpoint port is correct"
+		}
+		conn.Close()
+		return StatusPass, fmt.Sprintf("connected to %s in %s", conn.RemoteAddr(), time.Since(started).Round(time.Millisecond)), ""
+	})
+
+	d.run("tls", true, func() (string, string, string) {
+		if endpoint.Scheme != "https" {
+			return StatusWarn, "endpoint uses plain HTTP", "credentials and data are sent unencrypted; use https if the storage supports it"
+		}
+		return checkTLS(host, port)
+	})
+
+	d.run("clock", false, func() (string, string, string) {
+		return checkClock(ctx, endpoint)
+	})
+
+	d.run("credentials", false, func() (string, string, string) {
+		creds, err := s3lib.TargetCredentials(cfg, target)
+		if err != nil {
+			return StatusFail, err.Error(), "check S3_CREDENTIALS_PROVIDER and its settings"
+		}
+		value, err := creds.GetWithContext(ctx)
+		if err != nil {
+			return StatusFail, err.Error(), "the credentials provider could not return credentials; check its settings, files and permissions"
+		}
+		if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+			return StatusFail, "empty access key or secret key from " + value.ProviderName, "set S3_ACCESS_KEY and S3_SECRET_KEY or configure another provider"
+		}
+		return StatusPass, fmt.Sprintf("access key %s from %s", maskKey(value.AccessKeyID), value.ProviderName), ""
+	})
+
+	// Проверки S3 не блокируют друг друга: например, HeadBucket может быть запрещен политикой при разрешенной записи
+	d.run("head_bucket", false, func() (string, string, string) {
+		sess, err := s3lib.CreateSessionWithHTTP2(cfg, target)
+		if err != nil {
+			return StatusFail, err.Error(), ""
+		}
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+		_, err = s3lib.NewS3Client(sess, target).HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(target.S3Bucket)})
+		if err != nil {
+			return StatusFail, err.Error(), s3Hint(err)
+		}
+		return StatusPass, "bucket " + target.S3Bucket + " is accessible", ""
+	})
+
+	probe := doctorProbe(target)
+	uploaded := false
+	d.run("write", false, func() (string, string, string) {
+		if err := s3lib.UploadFileToS3(ctx, cfg, target, &probe); err != nil {
+			return StatusFail, err.Error(), s3Hint(err)
+		}
+		uploaded = true
+		return StatusPass, "uploaded " + probe.ObjectKey(), ""
+	})
+	d.run("read", false, func() (string, string, string) {
+		if !uploaded {
+			return StatusSkip, "requires write", ""
+		}
+		if _, err := s3lib.GetObject(ctx, cfg, target, &probe); err != nil {
+			if errors.Is(err, s3lib.ErrIntegrityCheckFailed) {
+				return StatusFail, err.Error(), "the object was read back with different content; check proxies, gateways and encryption settings between the probe and the storage"
+			}
+			return StatusFail, err.Error(), s3Hint(err)
+		}
+		return StatusPass, "read and verified " + probe.ObjectKey(), ""
+	})
+	d.run("delete", false, func() (string, string, string) {
+		if !uploaded {
+			return StatusSkip, "requires write", ""
+		}
+		if err := s3lib.DeleteFileFromS3(ctx, cfg, target, &probe); err != nil {
+			return StatusFail, err.Error(), s3Hint(err) + "; remove " + probe.ObjectKey() + " manually"
+		}
+		return StatusPass, "deleted " + probe.ObjectKey(), ""
+	})
+	return d.report
+}
+
+// endpointURL возвращает URL, по которому SDK обращается к бакету цели.
+func endpointURL(target *config.Target) (*url.URL, error) {
+	endpoint := target.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", target.S3Region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid endpoint %q: scheme and host are required", endpoint)
+	}
+	if target.AddressingStyle == config.AddressingVirtual {
+		u.Host = target.S3Bucket + "." + u.Host
+	}
+	return u, nil
+}
+
+// checkTLS выполняет TLS рукопожатие с проверкой сертификата. Пробы сертификат не проверяют,
+// поэтому недоверенный сертификат - предупреждение, а не ошибка.
+func checkTLS(host, port string) (string, string, string) {
+	dialer := &net.Dialer{Timeout: checkTimeout}
+	addr := net.JoinHostPort(host, port)
+	status, hint := StatusPass, ""
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		var verifyErr *tls.CertificateVerificationError
+		if !errors.As(err, &verifyErr) {
+			return StatusFail, err.Error(), "the server does not complete a TLS handshake; check that the endpoint port serves HTTPS"
+		}
+		status, hint = StatusWarn, "probes skip certificate verification, but clients that verify certificates will fail: "+err.Error()
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err != nil {
+			return StatusFail, err.Error(), "the server does not complete a TLS handshake; check that the endpoint port serves HTTPS"
+		}
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	cert := state.PeerCertificates[0]
+	detail := fmt.Sprintf("%s, certificate %q expires %s", tls.VersionName(state.Version), cert.Subject.CommonName, cert.NotAfter.Format(time.DateOnly))
+	switch left := time.Until(cert.NotAfter); {
+	case left <= 0:
+		return StatusFail, detail, "the certificate has expired; renew it"
+	case left < certExpiryWarning && status == StatusPass:
+		return StatusWarn, detail, fmt.Sprintf("the certificate expires in %d days; renew it", int(left.Hours()/24))
+	}
+	return status, detail, hint
+}
+
+// checkClock сравнивает локальное время со временем сервера из заголовка Date ответа эндпоинта.
+func checkClock(ctx context.Context, endpoint *url.URL) (string, string, string) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint.String(), nil)
+	if err != nil {
+		return StatusFail, err.Error(), ""
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	sent := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return StatusFail, err.Error(), "the endpoint does not answer HTTP requests; check proxies and the endpoint scheme"
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	received := time.Now()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return StatusWarn, "the response has no valid Date header", "clock skew could not be measured"
+	}
+	// Время сервера сравнивается с серединой запроса, точность заголовка Date - одна секунда
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(serverTime).Round(time.Second)
+	detail := fmt.Sprintf("local clock differs from the server by %s", skew)
+	switch abs := max(skew, -skew); {
+	case abs > maxClockSkew:
+		return StatusFail, detail, "S3 rejects signed requests with RequestTimeTooSkewed; sync the clock with NTP"
+	case abs > warnClockSkew:
+		return StatusWarn, detail, "sync the clock with NTP before the skew reaches 15 minutes"
+	}
+	return StatusPass, detail, ""
+}
+
+// doctorProbe возвращает пробу для проверки записи, чтения и удаления: небольшой объект с содержимым,
+// сгенерированным в памяти, и уникальным ключом. Таймауты берутся из первой пробы цели, повторы отключены.
+func doctorProbe(target *config.Target) config.Probe {
+	probe := config.Probe{
+		FileName:        "doctor",
+		Key:             fmt.Sprintf("%s%d", KeyPrefix, time.Now().UnixNano()),
+		PayloadSeed:     rand.Uint64(),
+		FileSizeBytes:   doctorObjectSize,
+		UploadTimeout:   10 * time.Second,
+		DownloadTimeout: 10 * time.Second,
+		DeleteTimeout:   10 * time.Second,
+	}
+	if len(target.Probes) > 0 {
+		first := target.Probes[0]
+		probe.UploadTimeout, probe.DownloadTimeout, probe.DeleteTimeout = first.UploadTimeout, first.DownloadTimeout, first.DeleteTimeout
+	}
+	return probe
+}
+
+// s3Hint возвращает подсказку по коду ошибки S3.
+func s3Hint(err error) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case "InvalidAccessKeyId":
+			return "the access key is unknown to the storage; check S3_ACCESS_KEY and the tenant"
+		case "SignatureDoesNotMatch":
+			return "check S3_SECRET_KEY, S3_SIGNATURE_VERSION and S3_PAYLOAD_SIGNING; proxies that rewrite the host or path also break signatures"
+		case "RequestTimeTooSkewed":
+			return "sync the clock with NTP"
+		case "ExpiredToken", "InvalidToken":
+			return "the session token has expired or is invalid; check the credentials provider"
+		case "AccessDenied", "Forbidden":
+			return "the credentials are valid but the bucket or user policy denies this operation"
+		case "NoSuchBucket", "NotFound":
+			return "the bucket does not exist; check S3_BUCKET and S3_REGION"
+		case "PermanentRedirect", "AuthorizationHeaderMalformed", "BadRequest":
+			return "the bucket may be in another region or need another addressing style; check S3_REGION and S3_ADDRESSING_STYLE"
+		}
+	}
+	switch s3lib.ErrorClass(err) {
+	case s3lib.ErrorClassTimeout:
+		return "the operation timed out; check network latency and the probe timeouts"
+	case s3lib.ErrorClassNetwork:
+		return "the request did not reach the storage; check the network checks above"
+	case s3lib.ErrorClassServer:
+		return "the storage returned a server error; check its health"
+	}
+	return "see the error for details"
+}
+
+// WriteText выводит результаты проверок целей с подсказками к неуспешным.
+func WriteText(w io.Writer, reports []Report) {
+	for _, report := range reports {
+		fmt.Fprintf(w, "Target %s (endpoint %s, bucket %s)\n", report.Target, report.Endpoint, report.Bucket)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, check := range report.Checks {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+			if check.Hint != "" {
+				fmt.Fprintf(tw, "  \t\thint: %s\n", check.Hint)
+			}
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+}
+
+// maskKey скрывает ключ доступа, оставляя первые символы для опознания.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + strings.Repeat("*", len(key)-4)
+}
@@ -1,10 +1,18 @@
 package health
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
 	"s3syn-test/internal/s3lib"
+	"s3syn-test/internal/watchdog"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"log/slog"
@@ -12,48 +20,238 @@ import (
 
 // HealthChecker содержит конфигурацию для проверки здоровья приложения.
 type HealthChecker struct {
-	Logger *slog.Logger
-	Sess   *session.Session
-	Bucket string
+	Logger   *slog.Logger
+	Targets  []TargetSession
+	Results  *results.Store
+	Mode     string
+	CacheTTL time.Duration
+	Timeout  time.Duration
+	Runs     int
+	Watchdog *watchdog.Watchdog
+
+	mu     sync.Mutex
+	cached *ReadinessReport
+}
+
+// TargetSession содержит AWS сессию, созданную из конфигурации цели.
+type TargetSession struct {
+	*config.Target
+	Sess *session.Session
+}
+
+// ReadinessReport - тело ответа readiness probe.
+type ReadinessReport struct {
+	Status    string        `json:"status"`
+	Mode      string        `json:"mode"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Reason    string        `json:"reason,omitempty"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// CheckResult - результат проверки одной цели или пробы.
+type CheckResult struct {
+	Target string `json:"target"`
+	File   string `json:"file,omitempty"`
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // NewHealthChecker создает новый экземпляр HealthChecker.
-func NewHealthChecker(cfg *config.Config) (*HealthChecker, error) {
-	sess, err := s3lib.CreateSessionWithHTTP2(cfg)
+func NewHealthChecker(cfg *config.Config, store *results.Store, wd *watchdog.Watchdog) (*HealthChecker, error) {
+	h := &HealthChecker{
+		Logger:   cfg.Logger,
+		Watchdog: wd,
+		Results:  store,
+		Mode:     cfg.ReadinessMode,
+		CacheTTL: cfg.ReadinessCacheTTL,
+		Timeout:  cfg.ReadinessTimeout,
+		Runs:     cfg.ReadinessRuns,
+	}
+	targets, err := NewTargetSessions(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return &HealthChecker{
-		Logger: cfg.Logger,
-		Sess:   sess,
-		Bucket: cfg.S3Bucket,
-	}, nil
+	h.Targets = targets
+	return h, nil
 }
 
-// HandleLiveness обрабатывает liveness probe.
+// NewTargetSessions создает AWS сессии для всех целей конфигурации.
+func NewTargetSessions(cfg *config.Config) ([]TargetSession, error) {
+	var targets []TargetSession
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		sess, err := s3lib.CreateSessionWithHTTP2(cfg, target)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, TargetSession{
+			Target: target,
+			Sess:   sess,
+		})
+	}
+	return targets, nil
+}
+
+// SetConfig заменяет проверяемые цели и параметры readiness после перезагрузки конфигурации cfg
+// и сбрасывает кэш readiness.
+func (h *HealthChecker) SetConfig(cfg *config.Config, targets []TargetSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.Targets = targets
+	h.Mode = cfg.ReadinessMode
+	h.CacheTTL = cfg.ReadinessCacheTTL
+	h.Timeout = cfg.ReadinessTimeout
+	h.Runs = cfg.ReadinessRuns
+	h.cached = nil
+}
+
+// LivenessReport - тело ответа liveness probe.
+type LivenessReport struct {
+	Status string           `json:"status"`
+	Reason string           `json:"reason,omitempty"`
+	Hung   []watchdog.Stall `json:"hung,omitempty"`
+}
+
+// HandleLiveness обрабатывает liveness probe. Проверка не проходит, если итерация какой-либо пробы
+// не завершилась в пределах лимита watchdog; в ответе указываются зависшие файлы и операции.
 func (h *HealthChecker) HandleLiveness(w http.ResponseWriter, r *http.Request) {
-	h.Logger.Info("Liveness probe succeeded")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	report := LivenessReport{Status: "ok"}
+	status := http.StatusOK
+	if h.Watchdog != nil {
+		if stalls := h.Watchdog.Stalls(); len(stalls) > 0 {
+			report.Status = "fail"
+			report.Hung = stalls
+			status = http.StatusServiceUnavailable
+			for _, stall := range stalls {
+				if stall.Operation != "" {
+					report.Reason = fmt.Sprintf("%s/%s: %s is running since %s", stall.Target, stall.File, stall.Operation, stall.Since.Format(time.RFC3339))
+					break
+				}
+			}
+			if report.Reason == "" {
+				report.Reason = fmt.Sprintf("%s/%s: no iteration finished within %s", stalls[0].Target, stalls[0].File, stalls[0].Limit)
+			}
+		}
+	}
+
+	if status == http.StatusOK {
+		h.Logger.Info("Liveness probe succeeded")
+	} else {
+		h.Logger.Error("Liveness probe failed", slog.String("reason", report.Reason))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
 }
 
-// HandleReadiness обрабатывает readiness probe.
+// HandleReadiness обрабатывает readiness probe и возвращает JSON с причиной неготовности.
 func (h *HealthChecker) HandleReadiness(w http.ResponseWriter, r *http.Request) {
-	if h.Sess == nil {
-		h.Logger.Error("AWS session is not initialized")
-		http.Error(w, "AWS session is not initialized", http.StatusServiceUnavailable)
-		return
+	report := h.readiness(r.Context())
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		h.Logger.Error("Readiness probe failed", slog.String("mode", report.Mode), slog.String("reason", report.Reason))
+		status = http.StatusServiceUnavailable
+	} else {
+		h.Logger.Info("Readiness probe succeeded")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// readiness выполняет проверку в настроенном режиме или возвращает закэшированный результат.
+func (h *HealthChecker) readiness(ctx context.Context) ReadinessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached != nil && h.CacheTTL > 0 && time.Since(h.cached.CheckedAt) < h.CacheTTL {
+		return *h.cached
+	}
+
+	report := ReadinessReport{Status: "ok", Mode: h.Mode, CheckedAt: time.Now()}
+	if h.Mode == config.ReadinessProbes {
+		report.Checks = h.checkProbes()
+	} else {
+		report.Checks = h.checkTargets(ctx)
+	}
+	for _, check := range report.Checks {
+		if !check.Ready {
+			report.Status = "fail"
+			report.Reason = check.Target
+			if check.File != "" {
+				report.Reason += "/" + check.File
+			}
+			report.Reason += ": " + check.Reason
+			break
+		}
+	}
+	h.cached = &report
+	return report
+}
+
+// checkTargets проверяет доступность S3 для каждой цели запросом ListBuckets или HeadBucket.
+func (h *HealthChecker) checkTargets(ctx context.Context) []CheckResult {
+	checks := make([]CheckResult, 0, len(h.Targets))
+	for _, target := range h.Targets {
+		check := CheckResult{Target: target.Name, Ready: true}
+		if err := h.checkTarget(ctx, target); err != nil {
+			check.Ready = false
+			check.Reason = err.Error()
+		}
+		checks = append(checks, check)
 	}
+	return checks
+}
 
-	svc := s3.New(h.Sess)
-	_, err := svc.ListBuckets(nil)
+func (h *HealthChecker) checkTarget(ctx context.Context, target TargetSession) error {
+	if target.Sess == nil {
+		return fmt.Errorf("AWS session is not initialized")
+	}
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	svc := s3lib.NewS3Client(target.Sess, target.Target)
+	var err error
+	if h.Mode == config.ReadinessHeadBucket {
+		_, err = svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(target.S3Bucket)})
+	} else {
+		_, err = svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	}
 	if err != nil {
-		h.Logger.Error("Failed to connect to S3", slog.Any("error", err))
-		http.Error(w, "Failed to connect to S3", http.StatusServiceUnavailable)
-		return
+		return fmt.Errorf("failed to connect to S3: %w", err)
 	}
+	return nil
+}
 
-	h.Logger.Info("Readiness probe succeeded")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// checkProbes проверяет, что последние Runs выполнений сценария каждой пробы прошли успешно.
+func (h *HealthChecker) checkProbes() []CheckResult {
+	keys := h.Results.Keys()
+	checks := make([]CheckResult, 0, len(keys))
+	for _, key := range keys {
+		check := CheckResult{Target: key.Target, File: key.File, Ready: true}
+		runs := h.Results.Last(key, h.Runs)
+		if len(runs) == 0 {
+			check.Ready = false
+			check.Reason = "no completed runs yet"
+		}
+		for i := len(runs) - 1; i >= 0; i-- {
+			failure := runs[i].Failure()
+			if failure == nil {
+				continue
+			}
+			check.Ready = false
+			check.Reason = fmt.Sprintf("run at %s failed: %s %s", runs[i].Started.Format(time.RFC3339), failure.Operation, failure.Outcome)
+			if failure.Error != "" {
+				check.Reason += ": " + failure.Error
+			}
+			break
+		}
+		checks = append(checks, check)
+	}
+	return checks
 }
@@ -0,0 +1,147 @@
+package load
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+)
+
+// progressInterval - интервал записи промежуточных результатов нагрузки в лог.
+const progressInterval = 10 * time.Second
+
+// KeyPrefixes возвращает префиксы ключей объектов, которые создают смешанная нагрузка и ramp.
+func KeyPrefixes() []string {
+	return []string{workloadFile + "-", rampFile + "-"}
+}
+
+// IsKey сообщает, что key - ключ объекта смешанной нагрузки (workload-<номер>) или ramp
+// (ramp-<размер>-load-<воркер>).
+func IsKey(key string) bool {
+	if n, ok := strings.CutPrefix(key, workloadFile+"-"); ok {
+		return isNumber(n)
+	}
+	if rest, ok := strings.CutPrefix(key, rampFile+"-"); ok {
+		size, worker, ok := strings.Cut(rest, ScenarioInfix)
+		return ok && isNumber(size) && isNumber(worker)
+	}
+	return false
+}
+
+// isNumber сообщает, состоит ли s только из цифр.
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// Task выполняет одну операцию нагрузки в воркере worker и возвращает ее результат
+// и число переданных байт.
+type Task func(worker int) (results.Run, int64)
+
+// Run выполняет task в opts.Workers параллельных воркерах, пока не истечет opts.Duration,
+// не будет запущено opts.Operations операций или не будет отменен ctx. Выполняющиеся операции
+// завершаются. Если задан opts.Rate, операции запускаются не чаще opts.Rate раз в секунду.
+func Run(ctx context.Context, cfg *config.Config, opts config.LoadOptions, task Task) *Report {
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	stats := newStats()
+	limiter := newLimiter(opts.Rate)
+	var issued atomic.Int64
+	start := time.Now()
+
+	progressCtx, stopProgress := context.WithCancel(context.Background())
+	defer stopProgress()
+	go logProgress(progressCtx, cfg.Logger, stats)
+
+	cfg.Logger.Info("Starting load", slog.Int("workers", opts.Workers), slog.Duration("duration", opts.Duration),
+		slog.Int("operations", opts.Operations), slog.Float64("rate", opts.Rate))
+	var wg sync.WaitGroup
+	for worker := range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if opts.Operations > 0 && issued.Add(1) > int64(opts.Operations) {
+					return
+				}
+				if !limiter.wait(ctx) {
+					return
+				}
+				run, bytes := task(worker)
+				stats.record(run, bytes)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := stats.report(time.Since(start))
+	report.Workers = opts.Workers
+	return report
+}
+
+// logProgress периодически записывает в лог число выполненных операций, их темп и число ошибок.
+func logProgress(ctx context.Context, logger *slog.Logger, stats *stats) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	var lastOperations int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			operations, failed := stats.counts()
+			logger.Info("Load progress",
+				slog.Int("operations", operations),
+				slog.Int("failed", failed),
+				slog.Float64("operations_per_second", float64(operations-lastOperations)/progressInterval.Seconds()))
+			lastOperations = operations
+		}
+	}
+}
+
+// limiter ограничивает темп запуска операций, распределяя их равномерно во времени.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait ждет очередного разрешенного момента запуска. Возвращает false, если ctx отменен.
+func (l *limiter) wait(ctx context.Context) bool {
+	if l.interval == 0 {
+		return ctx.Err() == nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,189 @@
+package load
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"s3syn-test/internal/config"
+)
+
+// rampFile - префикс имен объектов ramp.
+const rampFile = "ramp"
+
+// Причины завершения ступеней для размера объекта.
+const (
+	RampStopPlateau   = "plateau"
+	RampStopErrorRate = "error_rate"
+	RampStopLatency   = "p99"
+	RampStopCanceled  = "canceled"
+)
+
+// RampStep - результат одной ступени поиска точки насыщения.
+type RampStep struct {
+	Size                int     `json:"size_bytes"`
+	Workers             int     `json:"workers"`
+	Operations          int     `json:"operations"`
+	Failed              int     `json:"failed"`
+	ErrorRate           float64 `json:"error_rate"`
+	OperationsPerSecond float64 `json:"operations_per_second"`
+	BytesPerSecond      float64 `json:"bytes_per_second"`
+	P50                 float64 `json:"p50_seconds"`
+	P99                 float64 `json:"p99_seconds"`
+	Stop                string  `json:"stop,omitempty"`
+}
+
+// Ramp ищет точку насыщения хранилища: для каждого размера объекта увеличивает число воркеров
+// по ступеням и измеряет пропускную способность и p99 длительности операции на каждой ступени.
+type Ramp struct {
+	ctx       context.Context
+	cfg       *config.Config
+	opts      config.RampOptions
+	scenarios map[int]*Scenario // Сценарий ступени с наибольшим числом воркеров для каждого размера
+	files     []string
+}
+
+// NewRamp создает Ramp с параметрами из конфигурации. Операции с S3 прерываются при отмене ctx.
+func NewRamp(ctx context.Context, cfg *config.Config) *Ramp {
+	return &Ramp{ctx: ctx, cfg: cfg, opts: cfg.Ramp, scenarios: make(map[int]*Scenario)}
+}
+
+// Run выполняет ступени, пока не отменен ctx. Ступени для размера объекта завершаются, если прирост
+// пропускной способности меньше RAMP_PLATEAU или доля ошибок либо p99 превысили пороги. Если объекты
+// размера не удалось подготовить, возвращаются результаты выполненных ступеней и ошибка.
+func (r *Ramp) Run(ctx context.Context) ([]RampStep, error) {
+	var steps []RampStep
+	for _, size := range r.opts.Sizes {
+		probes, err := r.probes(size)
+		if err != nil {
+			// Без файла объекта ступени размера не выполнить; собранные результаты выводятся, объекты удаляются Cleanup
+			return steps, fmt.Errorf("prepare objects of size %d: %w", size, err)
+		}
+		var best float64
+		for _, workers := range r.opts.Workers {
+			if ctx.Err() != nil {
+				return steps, nil
+			}
+			scenario := newScenario(r.ctx, r.cfg, workers, probes)
+			// Объекты воркеров ступени с наибольшим числом воркеров включают объекты остальных ступеней размера
+			if prev, ok := r.scenarios[size]; !ok || len(prev.probes) < workers {
+				r.scenarios[size] = scenario
+			}
+			report := Run(ctx, r.cfg, config.LoadOptions{Workers: workers, Duration: r.opts.StepDuration}, scenario.Task)
+
+			step := RampStep{
+				Size:                size,
+				Workers:             workers,
+				Operations:          report.Operations,
+				Failed:              report.Failed,
+				ErrorRate:           report.ErrorRate,
+				OperationsPerSecond: report.OperationsPerSecond,
+				BytesPerSecond:      report.BytesPerSecond,
+				P50:                 report.P50,
+				P99:                 report.P99,
+			}
+			switch {
+			case ctx.Err() != nil:
+				step.Stop = RampStopCanceled
+			case report.ErrorRate > r.opts.MaxErrorRate:
+				step.Stop = RampStopErrorRate
+			case r.opts.MaxP99 > 0 && report.P99 > r.opts.MaxP99.Seconds():
+				step.Stop = RampStopLatency
+			case best > 0 && report.BytesPerSecond < best*(1+r.opts.Plateau):
+				step.Stop = RampStopPlateau
+			}
+			best = max(best, report.BytesPerSecond)
+			steps = append(steps, step)
+			r.cfg.Logger.Info("Ramp step finished",
+				slog.Int("size", size),
+				slog.Int("workers", workers),
+				slog.Float64("bytes_per_second", step.BytesPerSecond),
+				slog.Float64("p99_seconds", step.P99),
+				slog.Float64("error_rate", step.ErrorRate),
+				slog.String("stop", step.Stop))
+			if step.Stop != "" {
+				break
+			}
+		}
+	}
+	return steps, nil
+}
+
+// probes создает для каждой цели пробу с объектом размера size. Политика повторов берется
+// из первой пробы цели, таймауты операций - из RAMP_TIMEOUT.
+func (r *Ramp) probes(size int) ([]workerProbe, error) {
+	var probes []workerProbe
+	for i := range r.cfg.Targets {
+		target := &r.cfg.Targets[i]
+		var probe config.Probe
+		if len(target.Probes) > 0 {
+			probe = target.Probes[0]
+		}
+		probe.FileName = fmt.Sprintf("%s-%d", rampFile, size)
+		probe.Key = ""
+		probe.FileSizeBytes = size
+		probe.UploadTimeout = r.opts.Timeout
+		probe.DownloadTimeout = r.opts.Timeout
+		probe.DeleteTimeout = r.opts.Timeout
+		var err error
+		if probe.TempFile, err = r.cfg.CreateTempFileWithSize(target.FilesDir, probe.FileName, size); err != nil {
+			return nil, err
+		}
+		r.files = append(r.files, probe.TempFile)
+		probes = append(probes, workerProbe{target: target, probe: probe})
+	}
+	return probes, nil
+}
+
+// Cleanup удаляет объекты воркеров из бакетов и локальные файлы ступеней.
+func (r *Ramp) Cleanup(ctx context.Context) {
+	for _, scenario := range r.scenarios {
+		scenario.Cleanup(ctx)
+	}
+	for _, file := range r.files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			r.cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", file), slog.Any("error", err))
+		}
+	}
+}
+
+// WriteRamp выводит результаты ступеней таблицей или в формате CSV.
+func WriteRamp(w io.Writer, format string, steps []RampStep) error {
+	header := []string{"size_bytes", "workers", "operations", "failed", "error_rate", "ops_per_second", "mib_per_second", "p50_seconds", "p99_seconds", "stop"}
+	rows := make([][]string, 0, len(steps))
+	for _, step := range steps {
+		rows = append(rows, []string{
+			strconv.Itoa(step.Size),
+			strconv.Itoa(step.Workers),
+			strconv.Itoa(step.Operations),
+			strconv.Itoa(step.Failed),
+			strconv.FormatFloat(step.ErrorRate, 'f', 4, 64),
+			strconv.FormatFloat(step.OperationsPerSecond, 'f', 2, 64),
+			strconv.FormatFloat(step.BytesPerSecond/(1<<20), 'f', 2, 64),
+			strconv.FormatFloat(step.P50, 'f', 4, 64),
+			strconv.FormatFloat(step.P99, 'f', 4, 64),
+			step.Stop,
+		})
+	}
+
+	if format == config.RampOutputCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range append([][]string{header}, rows...) {
+		for _, cell := range row {
+			fmt.Fprint(tw, cell, "\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
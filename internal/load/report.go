@@ -0,0 +1,194 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"s3syn-test/internal/metrics"
+	"s3syn-test/internal/results"
+)
+
+// Report - итоговые результаты нагрузки.
+type Report struct {
+	Workers             int         `json:"workers"`
+	Elapsed             float64     `json:"elapsed_seconds"`
+	Operations          int         `json:"operations"`
+	Failed              int         `json:"failed"`
+	ErrorRate           float64     `json:"error_rate"`
+	OperationsPerSecond float64     `json:"operations_per_second"`
+	BytesPerSecond      float64     `json:"bytes_per_second"`
+	P50                 float64     `json:"p50_seconds"`
+	P99                 float64     `json:"p99_seconds"`
+	Steps               []StepStats `json:"steps"`
+}
+
+// StepStats - статистика одной операции сценария на одной цели.
+type StepStats struct {
+	Target       string         `json:"target"`
+	Operation    string         `json:"operation"`
+	SizeClass    string         `json:"size_class,omitempty"`
+	Count        int            `json:"count"`
+	Errors       int            `json:"errors"`
+	ErrorRate    float64        `json:"error_rate"`
+	P50          float64        `json:"p50_seconds"`
+	P90          float64        `json:"p90_seconds"`
+	P95          float64        `json:"p95_seconds"`
+	P99          float64        `json:"p99_seconds"`
+	Max          float64        `json:"max_seconds"`
+	ErrorClasses map[string]int `json:"error_classes,omitempty"`
+}
+
+// WriteText выводит отчет в виде таблицы.
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Workers: %d, elapsed: %.1fs\n", r.Workers, r.Elapsed)
+	fmt.Fprintf(w, "Operations: %d, failed: %d (%.2f%%)\n", r.Operations, r.Failed, r.ErrorRate*100)
+	fmt.Fprintf(w, "Throughput: %.2f ops/s, %.2f MiB/s\n", r.OperationsPerSecond, r.BytesPerSecond/(1<<20))
+	fmt.Fprintf(w, "Operation latency: p50 %s, p99 %s\n\n", formatSeconds(r.P50), formatSeconds(r.P99))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TARGET\tOPERATION\tSIZE\tCOUNT\tERRORS\tP50\tP90\tP95\tP99\tMAX\t")
+	for _, step := range r.Steps {
+		sizeClass := step.SizeClass
+		if sizeClass == "" {
+			sizeClass = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n", step.Target, step.Operation, sizeClass, step.Count, step.Errors,
+			formatSeconds(step.P50), formatSeconds(step.P90), formatSeconds(step.P95), formatSeconds(step.P99), formatSeconds(step.Max))
+	}
+	tw.Flush()
+}
+
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(10 * time.Microsecond).String()
+}
+
+type stepKey struct {
+	target    string
+	operation string
+	sizeClass string
+}
+
+type stepSamples struct {
+	durations    []float64
+	errors       int
+	errorClasses map[string]int
+}
+
+// stats накапливает результаты операций нагрузки и обновляет метрики нагрузки.
+type stats struct {
+	mu         sync.Mutex
+	operations int
+	failed     int
+	bytes      int64
+	durations  []float64
+	steps      map[stepKey]*stepSamples
+	order      []stepKey
+}
+
+func newStats() *stats {
+	return &stats{steps: make(map[stepKey]*stepSamples)}
+}
+
+func (s *stats) record(run results.Run, bytes int64) {
+	outcome := results.OutcomeOK
+	if !run.OK {
+		outcome = results.OutcomeError
+	}
+	sizeClass := metrics.SizeClass(run.Size)
+	metrics.LoadOperations.WithLabelValues(run.Target, sizeClass, outcome).Inc()
+	metrics.LoadBytes.WithLabelValues(run.Target, sizeClass).Add(float64(bytes))
+	for _, step := range run.Steps {
+		metrics.LoadStepDuration.WithLabelValues(run.Target, step.Operation, sizeClass).Observe(step.Duration)
+		metrics.LoadSteps.WithLabelValues(run.Target, step.Operation, sizeClass, step.Outcome).Inc()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.operations++
+	if !run.OK {
+		s.failed++
+	}
+	s.bytes += bytes
+	s.durations = append(s.durations, run.Finished.Sub(run.Started).Seconds())
+	for _, step := range run.Steps {
+		key := stepKey{target: run.Target, operation: step.Operation, sizeClass: sizeClass}
+		samples, ok := s.steps[key]
+		if !ok {
+			samples = &stepSamples{errorClasses: make(map[string]int)}
+			s.steps[key] = samples
+			s.order = append(s.order, key)
+		}
+		samples.durations = append(samples.durations, step.Duration)
+		if step.Outcome != results.OutcomeOK {
+			samples.errors++
+			samples.errorClasses[step.ErrorClass]++
+		}
+	}
+}
+
+func (s *stats) counts() (operations, failed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.operations, s.failed
+}
+
+func (s *stats) report(elapsed time.Duration) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{
+		Elapsed:    elapsed.Seconds(),
+		Operations: s.operations,
+		Failed:     s.failed,
+		Steps:      []StepStats{},
+	}
+	if s.operations > 0 {
+		report.ErrorRate = float64(s.failed) / float64(s.operations)
+	}
+	if elapsed > 0 {
+		report.OperationsPerSecond = float64(s.operations) / elapsed.Seconds()
+		report.BytesPerSecond = float64(s.bytes) / elapsed.Seconds()
+	}
+	durations := slices.Clone(s.durations)
+	slices.Sort(durations)
+	report.P50 = percentile(durations, 50)
+	report.P99 = percentile(durations, 99)
+	for _, key := range s.order {
+		samples := s.steps[key]
+		durations := slices.Clone(samples.durations)
+		slices.Sort(durations)
+		step := StepStats{
+			Target:    key.target,
+			Operation: key.operation,
+			SizeClass: key.sizeClass,
+			Count:     len(durations),
+			Errors:    samples.errors,
+			ErrorRate: float64(samples.errors) / float64(len(durations)),
+			P50:       percentile(durations, 50),
+			P90:       percentile(durations, 90),
+			P95:       percentile(durations, 95),
+			P99:       percentile(durations, 99),
+			Max:       durations[len(durations)-1],
+		}
+		if samples.errors > 0 {
+			step.ErrorClasses = samples.errorClasses
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	return report
+}
+
+// percentile возвращает p-й процентиль отсортированных значений (nearest-rank).
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
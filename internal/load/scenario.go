@@ -0,0 +1,103 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/s3lib"
+)
+
+// ScenarioInfix отделяет ключ пробы от номера воркера в ключах объектов сценария нагрузки.
+const ScenarioInfix = "-load-"
+
+// Scenario выполняет в воркерах нагрузки сценарий проб: загрузку, скачивание, проверку целостности и удаление.
+// Каждый воркер работает со своими объектами, чтобы воркеры не мешали друг другу.
+type Scenario struct {
+	ctx    context.Context
+	cfg    *config.Config
+	probes [][]workerProbe
+	next   []int
+}
+
+type workerProbe struct {
+	target *config.Target
+	probe  config.Probe
+}
+
+// NewScenario создает сценарий для workers воркеров по всем пробам всех целей.
+// Операции с S3 прерываются при отмене ctx.
+func NewScenario(ctx context.Context, cfg *config.Config, workers int) *Scenario {
+	var probes []workerProbe
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		for _, probe := range target.Probes {
+			probes = append(probes, workerProbe{target: target, probe: probe})
+		}
+	}
+	return newScenario(ctx, cfg, workers, probes)
+}
+
+// newScenario создает сценарий, в котором каждый воркер выполняет по кругу копии проб probes.
+func newScenario(ctx context.Context, cfg *config.Config, workers int, probes []workerProbe) *Scenario {
+	s := &Scenario{ctx: ctx, cfg: cfg, probes: make([][]workerProbe, workers), next: make([]int, workers)}
+	for worker := range workers {
+		for _, p := range probes {
+			p.probe.Key = fmt.Sprintf("%s%s%d", p.probe.FileName, ScenarioInfix, worker)
+			s.probes[worker] = append(s.probes[worker], p)
+		}
+	}
+	return s
+}
+
+// Task выполняет сценарий очередной пробы воркера. Пробы воркера выполняются по кругу.
+// Если задано распределение размеров, объект каждого сценария получает случайный размер
+// и сгенерированное содержимое.
+func (s *Scenario) Task(worker int) (results.Run, int64) {
+	probes := s.probes[worker]
+	p := &probes[s.next[worker]%len(probes)]
+	s.next[worker]++
+
+	probe := p.probe
+	if s.cfg.Load.Sizes != nil {
+		probe.FileSizeBytes = s.cfg.Load.Sizes.Sample()
+		probe.TempFile = ""
+		probe.PayloadSeed = rand.Uint64()
+	}
+	run := s3lib.ProcessFile(s.ctx, s.cfg, p.target, &probe, nil)
+	var bytes int64
+	for _, step := range run.Steps {
+		if step.Outcome == results.OutcomeOK && (step.Operation == "upload" || step.Operation == "download") {
+			bytes += int64(probe.FileSizeBytes)
+		}
+	}
+	return run, bytes
+}
+
+// Cleanup удаляет объекты воркеров из бакетов и скачанные файлы, оставшиеся после прерванных сценариев.
+func (s *Scenario) Cleanup(ctx context.Context) {
+	var wg sync.WaitGroup
+	for worker := range s.probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range s.probes[worker] {
+				p := &s.probes[worker][i]
+				if err := s3lib.CleanupProbe(ctx, s.cfg, p.target, &p.probe); err != nil {
+					s.cfg.Logger.Warn("Failed to clean up load objects", slog.String("target", p.target.Name), slog.String("key", p.probe.ObjectKey()), slog.Any("error", err))
+				}
+				tempFilePath := p.probe.DownloadPath(p.target.FilesDir)
+				if err := os.Remove(tempFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+					s.cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", tempFilePath), slog.Any("error", err))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,164 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/s3lib"
+)
+
+// workloadFile - имя файла в метриках и результатах операций смешанной нагрузки.
+const workloadFile = "workload"
+
+// Workload выполняет смешанную нагрузку: операции GET, PUT, HEAD и LIST, выбираемые случайно
+// с заданными весами, над набором заранее загруженных объектов каждой цели.
+type Workload struct {
+	ctx     context.Context
+	cfg     *config.Config
+	weights []config.OperationWeight
+	total   int
+	targets []*config.Target
+	objects [][]config.Probe // Объекты targets[i]
+}
+
+// NewWorkload создает смешанную нагрузку. На каждой цели используются WORKLOAD_OBJECTS объектов
+// со сгенерированным содержимым. Таймауты объектов по очереди берутся из проб цели, размеры - из
+// распределения SIZE_DISTRIBUTION или, если оно не задано, из проб. Цели без проб пропускаются.
+// Операции с S3 прерываются при отмене ctx.
+func NewWorkload(ctx context.Context, cfg *config.Config) *Workload {
+	w := &Workload{ctx: ctx, cfg: cfg, weights: cfg.Load.Workload}
+	for _, weight := range w.weights {
+		w.total += weight.Weight
+	}
+	for t := range cfg.Targets {
+		target := &cfg.Targets[t]
+		if len(target.Probes) == 0 {
+			cfg.Logger.Warn("Target has no probes, skipping it in workload", slog.String("target", target.Name))
+			continue
+		}
+		w.targets = append(w.targets, target)
+		w.objects = append(w.objects, nil)
+		i := len(w.targets) - 1
+		for n := range cfg.Load.WorkloadObjects {
+			object := target.Probes[n%len(target.Probes)]
+			object.FileName = workloadFile
+			object.Key = fmt.Sprintf("%s-%d", workloadFile, n)
+			object.TempFile = ""
+			object.PayloadSeed = rand.Uint64()
+			if cfg.Load.Sizes != nil {
+				object.FileSizeBytes = cfg.Load.Sizes.Sample()
+			}
+			w.objects[i] = append(w.objects[i], object)
+		}
+	}
+	return w
+}
+
+// Prepare загружает набор объектов на все цели в workers параллельных потоков.
+func (w *Workload) Prepare(ctx context.Context, workers int) error {
+	type job struct {
+		target *config.Target
+		object *config.Probe
+	}
+	jobs := make(chan job)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := s3lib.UploadFileToS3(ctx, w.cfg, j.target, j.object); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s/%s: %w", j.target.Name, j.object.ObjectKey(), err)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	w.cfg.Logger.Info("Uploading workload objects", slog.Int("objects", w.cfg.Load.WorkloadObjects), slog.Int("targets", len(w.targets)))
+	for i := range w.targets {
+		for n := range w.objects[i] {
+			if ctx.Err() != nil {
+				break
+			}
+			jobs <- job{target: w.targets[i], object: &w.objects[i][n]}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// Task выполняет одну операцию над случайным объектом случайной цели.
+func (w *Workload) Task(int) (results.Run, int64) {
+	i := rand.IntN(len(w.targets))
+	target := w.targets[i]
+	object := w.objects[i][rand.IntN(len(w.objects[i]))]
+	operation := w.pick()
+
+	run := results.Run{Target: target.Name, File: workloadFile, Started: time.Now()}
+	if operation != config.OperationList {
+		run.Size = object.FileSizeBytes
+	}
+	var bytes int64
+	s3lib.RunStep(&run, nil, operation, func(opt request.Option) (err error) {
+		switch operation {
+		case config.OperationPut:
+			if err = s3lib.UploadFileToS3(w.ctx, w.cfg, target, &object, opt); err == nil {
+				bytes = int64(object.FileSizeBytes)
+			}
+		case config.OperationGet:
+			bytes, err = s3lib.GetObject(w.ctx, w.cfg, target, &object, opt)
+		case config.OperationHead:
+			err = s3lib.HeadObject(w.ctx, w.cfg, target, &object, opt)
+		case config.OperationList:
+			_, err = s3lib.ListObjects(w.ctx, w.cfg, target, &object, workloadFile+"-", opt)
+		}
+		return err
+	})
+	run.Finished = time.Now()
+	run.OK = run.Failure() == nil
+	return run, bytes
+}
+
+// pick выбирает операцию случайно с учетом весов.
+func (w *Workload) pick() string {
+	n := rand.IntN(w.total)
+	for _, weight := range w.weights {
+		if n < weight.Weight {
+			return weight.Operation
+		}
+		n -= weight.Weight
+	}
+	return w.weights[len(w.weights)-1].Operation
+}
+
+// Cleanup удаляет набор объектов со всех целей.
+func (w *Workload) Cleanup(ctx context.Context) {
+	for i, target := range w.targets {
+		keys := make([]string, 0, len(w.objects[i]))
+		for _, object := range w.objects[i] {
+			keys = append(keys, object.ObjectKey())
+		}
+		if err := s3lib.DeleteObjects(ctx, w.cfg, target, keys); err != nil {
+			w.cfg.Logger.Warn("Failed to delete workload objects", slog.String("target", target.Name), slog.Any("error", err))
+		}
+	}
+}
@@ -6,29 +6,124 @@ var (
 	UploadDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_upload_duration_seconds",
 		Help: "Time taken to upload file to S3",
-	}, []string{"file"})
+	}, []string{"target", "endpoint", "bucket", "file"})
 	DownloadDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_download_duration_seconds",
 		Help: "Time taken to download file from S3",
-	}, []string{"file"})
+	}, []string{"target", "endpoint", "bucket", "file"})
 	DeleteDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_delete_duration_seconds",
 		Help: "Time taken to delete file from S3",
-	}, []string{"file"})
+	}, []string{"target", "endpoint", "bucket", "file"})
 	FileIsCorrected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_file_is_correct",
 		Help: "File integrity check (1 if OK, 0 if corrupted)",
-	}, []string{"file"})
+	}, []string{"target", "endpoint", "bucket", "file"})
 	TimeoutMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_operation_timeout",
 		Help: "Operation timeout exceeded (1 if timeout exceeded, 0 otherwise)",
-	}, []string{"file", "operation"})
+	}, []string{"target", "endpoint", "bucket", "file", "operation"})
 	IsError = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "s3_operation_is_error",
 		Help: "An error occurred while performing the operation (1 if error occurred, 0 otherwise)",
-	}, []string{"file", "operation"})
+	}, []string{"target", "endpoint", "bucket", "file", "operation"})
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operation_retries_total",
+		Help: "Number of request retries performed by the SDK during the operation",
+	}, []string{"target", "endpoint", "bucket", "file", "operation"})
+	FirstAttemptSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "s3_operation_first_attempt_success",
+		Help: "Operation succeeded without retries (1 if succeeded on the first attempt, 0 otherwise)",
+	}, []string{"target", "endpoint", "bucket", "file", "operation"})
+	ScheduleLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "s3_probe_schedule_lag_seconds",
+		Help: "Delay between the scheduled and the actual start of the last probe run",
+	}, []string{"target", "endpoint", "bucket", "file"})
+	ScheduleMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_probe_schedule_missed_total",
+		Help: "Number of scheduled probe runs skipped because the previous run was still in progress",
+	}, []string{"target", "endpoint", "bucket", "file"})
+	ProbeLabel = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "s3_probe_label",
+		Help: "Label of a probe from its configuration, always 1",
+	}, []string{"target", "file", "label", "value"})
+	CleanupObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_cleanup_deleted_objects_total",
+		Help: "Number of orphaned probe objects deleted by cleanup",
+	}, []string{"target", "endpoint", "bucket"})
+	CleanupUploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_cleanup_aborted_uploads_total",
+		Help: "Number of stale multipart uploads aborted by cleanup",
+	}, []string{"target", "endpoint", "bucket"})
+	ConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_config_reloads_total",
+		Help: "Number of configuration reloads by result (success, failure)",
+	}, []string{"result"})
+	LoadOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_load_operations_total",
+		Help: "Number of operations completed in load mode",
+	}, []string{"target", "size_class", "outcome"})
+	LoadSteps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_load_steps_total",
+		Help: "Number of S3 operation steps completed in load mode",
+	}, []string{"target", "operation", "size_class", "outcome"})
+	LoadStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "s3_load_step_duration_seconds",
+		Help:    "Duration of S3 operation steps in load mode",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+	}, []string{"target", "operation", "size_class"})
+	LoadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_load_bytes_total",
+		Help: "Number of bytes uploaded and downloaded in load mode",
+	}, []string{"target", "size_class"})
 )
 
+// Границы классов размеров объектов.
+var sizeClasses = []struct {
+	limit int
+	name  string
+}{
+	{4 << 10, "0-4KiB"},
+	{64 << 10, "4KiB-64KiB"},
+	{1 << 20, "64KiB-1MiB"},
+	{16 << 20, "1MiB-16MiB"},
+	{256 << 20, "16MiB-256MiB"},
+}
+
+// SizeClass возвращает класс размера объекта для метки size_class, чтобы при случайных размерах
+// число значений метки оставалось ограниченным. Для операций без объекта (size 0) возвращается пустая строка.
+func SizeClass(size int) string {
+	if size <= 0 {
+		return ""
+	}
+	for _, class := range sizeClasses {
+		if size <= class.limit {
+			return class.name
+		}
+	}
+	return "256MiB+"
+}
+
+// DeleteProbe удаляет серии метрик пробы, например после ее удаления из конфигурации.
+func DeleteProbe(target, file string) {
+	labels := prometheus.Labels{"target": target, "file": file}
+	for _, gauge := range []*prometheus.GaugeVec{UploadDuration, DownloadDuration, DeleteDuration, FileIsCorrected,
+		TimeoutMetric, IsError, FirstAttemptSuccess, ScheduleLag, ProbeLabel} {
+		gauge.DeletePartialMatch(labels)
+	}
+	for _, counter := range []*prometheus.CounterVec{Retries, ScheduleMissed} {
+		counter.DeletePartialMatch(labels)
+	}
+}
+
+// SetProbeLabels заменяет ряды s3_probe_label пробы метками labels.
+func SetProbeLabels(target, file string, labels map[string]string) {
+	ProbeLabel.DeletePartialMatch(prometheus.Labels{"target": target, "file": file})
+	for label, value := range labels {
+		ProbeLabel.WithLabelValues(target, file, label, value).Set(1)
+	}
+}
+
 func Init() {
 	prometheus.MustRegister(UploadDuration)
 	prometheus.MustRegister(DownloadDuration)
@@ -36,4 +131,16 @@ func Init() {
 	prometheus.MustRegister(FileIsCorrected)
 	prometheus.MustRegister(TimeoutMetric)
 	prometheus.MustRegister(IsError)
+	prometheus.MustRegister(Retries)
+	prometheus.MustRegister(FirstAttemptSuccess)
+	prometheus.MustRegister(ScheduleLag)
+	prometheus.MustRegister(ScheduleMissed)
+	prometheus.MustRegister(ProbeLabel)
+	prometheus.MustRegister(CleanupObjects)
+	prometheus.MustRegister(CleanupUploads)
+	prometheus.MustRegister(ConfigReloads)
+	prometheus.MustRegister(LoadOperations)
+	prometheus.MustRegister(LoadSteps)
+	prometheus.MustRegister(LoadStepDuration)
+	prometheus.MustRegister(LoadBytes)
 }
@@ -0,0 +1,85 @@
+package once
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/s3lib"
+)
+
+// Summary - итоги однократного выполнения всех проб.
+type Summary struct {
+	OK      bool          `json:"ok"`
+	Started time.Time     `json:"started"`
+	Elapsed float64       `json:"elapsed_seconds"`
+	Probes  int           `json:"probes"`
+	Failed  int           `json:"failed"`
+	Runs    []results.Run `json:"runs"`
+}
+
+// Run выполняет сценарий каждой пробы всех целей один раз. Пробы выполняются параллельно,
+// результаты в отчете идут в порядке конфигурации. Отмена ctx прерывает выполняемые операции.
+func Run(ctx context.Context, cfg *config.Config) *Summary {
+	summary := &Summary{Started: time.Now()}
+	var runs [][]results.Run
+	var wg sync.WaitGroup
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		targetRuns := make([]results.Run, len(target.Probes))
+		runs = append(runs, targetRuns)
+		for j := range target.Probes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				targetRuns[j] = s3lib.ProcessFile(ctx, cfg, target, &target.Probes[j], nil)
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, targetRuns := range runs {
+		for _, run := range targetRuns {
+			summary.Runs = append(summary.Runs, run)
+			if !run.OK {
+				summary.Failed++
+			}
+		}
+	}
+	summary.Probes = len(summary.Runs)
+	summary.OK = summary.Failed == 0
+	summary.Elapsed = time.Since(summary.Started).Seconds()
+	return summary
+}
+
+// WriteText выводит итоги в виде таблицы шагов каждой пробы.
+func (s *Summary) WriteText(w io.Writer) {
+	status := "PASSED"
+	if !s.OK {
+		status = "FAILED"
+	}
+	fmt.Fprintf(w, "%s: %d of %d probes failed, elapsed: %.1fs\n\n", status, s.Failed, s.Probes, s.Elapsed)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tFILE\tOPERATION\tOUTCOME\tDURATION\tERROR")
+	for _, run := range s.Runs {
+		for _, step := range run.Steps {
+			duration := time.Duration(step.Duration * float64(time.Second)).Round(time.Millisecond)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", run.Target, run.File, step.Operation, step.Outcome, duration, step.Error)
+		}
+	}
+	tw.Flush()
+}
+
+// WriteJSON выводит итоги в формате JSON.
+func (s *Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
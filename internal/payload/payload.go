@@ -0,0 +1,122 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMismatch возвращается, если прочитанные данные не совпадают со сгенерированными.
+var ErrMismatch = errors.New("payload mismatch")
+
+// verifyBufferSize - размер блока, которым сравниваются данные при проверке.
+const verifyBufferSize = 64 * 1024
+
+// Reader генерирует детерминированное псевдослучайное содержимое размера size, определяемое seed.
+// Содержимое не хранится в памяти и может быть сгенерировано повторно для проверки целостности.
+type Reader struct {
+	seed   uint64
+	size   int64
+	offset int64
+}
+
+// New создает Reader.
+func New(seed uint64, size int64) *Reader {
+	return &Reader{seed: seed, size: size}
+}
+
+// Read реализует io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+// ReadAt реализует io.ReaderAt.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if remaining := r.size - off; int64(n) > remaining {
+		n = int(remaining)
+	}
+	fill(p[:n], r.seed, off)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek реализует io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close реализует io.Closer.
+func (r *Reader) Close() error {
+	return nil
+}
+
+// Verify читает r и проверяет, что данные совпадают с содержимым, сгенерированным по seed и size.
+func Verify(r io.Reader, seed uint64, size int64) error {
+	got := make([]byte, verifyBufferSize)
+	want := make([]byte, verifyBufferSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, got)
+		if n > 0 {
+			if offset+int64(n) > size {
+				return fmt.Errorf("%w: read more than %d bytes", ErrMismatch, size)
+			}
+			fill(want[:n], seed, offset)
+			if !bytes.Equal(got[:n], want[:n]) {
+				return fmt.Errorf("%w: data differs in block at offset %d", ErrMismatch, offset)
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if offset != size {
+		return fmt.Errorf("%w: read %d bytes, expected %d", ErrMismatch, offset, size)
+	}
+	return nil
+}
+
+// fill заполняет p содержимым, начиная с позиции off. Каждые 8 байт содержимого - значение splitmix64
+// от seed и номера 8-байтового слова, поэтому любой участок можно сгенерировать независимо.
+func fill(p []byte, seed uint64, off int64) {
+	var word [8]byte
+	for i := 0; i < len(p); {
+		pos := off + int64(i)
+		binary.LittleEndian.PutUint64(word[:], splitmix64(seed+uint64(pos/8)))
+		i += copy(p[i:], word[pos%8:])
+	}
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,128 @@
+package probes
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Authorize пропускает запрос к next, только если он содержит заголовок "Authorization: Bearer <token>".
+func Authorize(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing API token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HandleList возвращает определения всех проб.
+func (m *Manager) HandleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, m.List())
+}
+
+// HandleGet возвращает определение пробы {target}/{file}.
+func (m *Manager) HandleGet(w http.ResponseWriter, r *http.Request) {
+	def, err := m.Get(r.PathValue("target"), r.PathValue("file"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, def)
+}
+
+// HandleCreate создает пробу по определению из тела запроса.
+func (m *Manager) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var def Definition
+	if !decode(w, r, &def) {
+		return
+	}
+	def, err := m.Create(def)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, def)
+}
+
+// HandleUpdate заменяет определение пробы {target}/{file} определением из тела запроса.
+func (m *Manager) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	var def Definition
+	if !decode(w, r, &def) {
+		return
+	}
+	def.Target, def.File = r.PathValue("target"), r.PathValue("file")
+	def, err := m.Update(def)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, def)
+}
+
+// HandlePause приостанавливает пробу {target}/{file}.
+func (m *Manager) HandlePause(w http.ResponseWriter, r *http.Request) {
+	m.handleSetPaused(w, r, true)
+}
+
+// HandleResume возобновляет запуск пробы {target}/{file} по расписанию.
+func (m *Manager) HandleResume(w http.ResponseWriter, r *http.Request) {
+	m.handleSetPaused(w, r, false)
+}
+
+func (m *Manager) handleSetPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	def, err := m.SetPaused(r.PathValue("target"), r.PathValue("file"), paused)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, def)
+}
+
+// HandleDelete удаляет пробу {target}/{file}.
+func (m *Manager) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := m.Delete(r.Context(), r.PathValue("target"), r.PathValue("file")); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, errSaveState):
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
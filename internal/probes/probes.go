@@ -0,0 +1,678 @@
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/metrics"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/s3lib"
+	"s3syn-test/internal/schedule"
+	"s3syn-test/internal/watchdog"
+)
+
+var (
+	ErrNotFound  = errors.New("probe not found")
+	ErrExists    = errors.New("probe already exists")
+	errSaveState = errors.New("failed to save state file")
+)
+
+// Definition описывает пробу в API управления и в файле состояния. Размер задается числом байт или строкой
+// с единицами ("4KiB"), таймауты и задержка запуска - числом секунд или длительностью ("250ms", "1m30s"),
+// задержки повторов - числом миллисекунд или длительностью.
+type Definition struct {
+	Target          string            `json:"target"`
+	File            string            `json:"file"`
+	SizeBytes       config.Value      `json:"size_bytes"`
+	UploadTimeout   config.Value      `json:"upload_timeout_seconds"`
+	DownloadTimeout config.Value      `json:"download_timeout_seconds"`
+	DeleteTimeout   config.Value      `json:"delete_timeout_seconds"`
+	Schedule        string            `json:"schedule,omitempty"` // Интервал или cron выражение, по умолчанию TASK_INTERVAL
+	Jitter          config.Value      `json:"jitter_seconds,omitempty"`
+	MaxRetries      *int              `json:"max_retries,omitempty"`
+	RetryMinDelay   config.Value      `json:"retry_min_delay_ms,omitempty"`
+	RetryMaxDelay   config.Value      `json:"retry_max_delay_ms,omitempty"`
+	RetryableCodes  []string          `json:"retryable_codes,omitempty"`
+	Scenario        []string          `json:"scenario,omitempty"` // По умолчанию upload, download, verify, delete
+	Labels          map[string]string `json:"labels,omitempty"`
+	Paused          bool              `json:"paused"`
+}
+
+// Entry - проба и ее цель.
+type Entry struct {
+	Target *config.Target
+	Probe  config.Probe
+}
+
+// ConfigEntries возвращает пробы всех целей из конфигурации.
+func ConfigEntries(cfg *config.Config) []Entry {
+	var entries []Entry
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		for _, probe := range target.Probes {
+			entries = append(entries, Entry{Target: target, Probe: probe})
+		}
+	}
+	return entries
+}
+
+// Iteration выполняет одну итерацию пробы.
+type Iteration func(target *config.Target, probe *config.Probe)
+
+// Manager хранит определения проб, запускает их по расписанию и позволяет изменять их во время работы.
+// Если задан файл состояния, определения сохраняются в него после каждого изменения и при запуске
+// загружаются из него вместо проб из конфигурации.
+type Manager struct {
+	store     *results.Store
+	wd        *watchdog.Watchdog
+	iterate   Iteration
+	stateFile string
+
+	// changeMu упорядочивает изменения, mu защищает список проб и конфигурацию
+	changeMu  sync.Mutex
+	mu        sync.Mutex
+	cfg       *config.Config
+	fromState bool // Пробы определяются файлом состояния, а не конфигурацией
+	ctx       context.Context
+	stopped   bool
+	probes    map[results.Key]*entry
+	order     []results.Key
+	loops     sync.WaitGroup
+}
+
+type entry struct {
+	target *config.Target
+	probe  *config.Probe
+	paused bool
+	stop   context.CancelFunc
+	done   chan struct{}
+}
+
+// New создает Manager с пробами из конфигурации или, если он существует, из файла состояния stateFile.
+func New(cfg *config.Config, store *results.Store, wd *watchdog.Watchdog, stateFile string, iterate Iteration) (*Manager, error) {
+	m := &Manager{
+		cfg:       cfg,
+		store:     store,
+		wd:        wd,
+		iterate:   iterate,
+		stateFile: stateFile,
+		probes:    make(map[results.Key]*entry),
+	}
+	definitions, err := m.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if definitions == nil {
+		for _, e := range ConfigEntries(cfg) {
+			m.add(&entry{target: e.Target, probe: &e.Probe})
+		}
+		return m, nil
+	}
+	cfg.Logger.Info("Loaded probes from state file", slog.String("file", stateFile), slog.Int("probes", len(definitions)))
+	m.fromState = true
+	for _, def := range definitions {
+		e, err := m.newEntry(def, nil)
+		if err != nil {
+			return nil, fmt.Errorf("state file %s: probe %s/%s: %w", stateFile, def.Target, def.File, err)
+		}
+		if _, ok := m.probes[key(e)]; ok {
+			return nil, fmt.Errorf("state file %s: probe %s/%s: %w", stateFile, def.Target, def.File, ErrExists)
+		}
+		m.add(e)
+	}
+	return m, nil
+}
+
+// Start запускает по расписанию все пробы, кроме приостановленных. Отмена ctx останавливает запуск новых итераций.
+func (m *Manager) Start(ctx context.Context) error {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	m.ctx = ctx
+	for _, k := range m.order {
+		e := m.probes[k]
+		m.register(e)
+		if e.paused {
+			continue
+		}
+		if err := m.start(e); err != nil {
+			return fmt.Errorf("probe %s/%s: %w", k.Target, k.File, err)
+		}
+	}
+	return nil
+}
+
+// Wait ждет отмены контекста Start и завершения циклов всех проб. После отмены новые циклы не запускаются.
+func (m *Manager) Wait() {
+	<-m.ctx.Done()
+	m.changeMu.Lock()
+	m.stopped = true
+	m.changeMu.Unlock()
+	m.loops.Wait()
+}
+
+// Config возвращает текущую конфигурацию, с которой работают пробы.
+func (m *Manager) Config() *config.Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cfg
+}
+
+// Entries возвращает текущие пробы всех целей.
+func (m *Manager) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]Entry, 0, len(m.order))
+	for _, k := range m.order {
+		e := m.probes[k]
+		entries = append(entries, Entry{Target: e.target, Probe: *e.probe})
+	}
+	return entries
+}
+
+// TargetProbes возвращает текущие пробы цели.
+func (m *Manager) TargetProbes(target string) []config.Probe {
+	var probes []config.Probe
+	for _, e := range m.Entries() {
+		if e.Target.Name == target {
+			probes = append(probes, e.Probe)
+		}
+	}
+	return probes
+}
+
+// List возвращает определения всех проб.
+func (m *Manager) List() []Definition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.definitions()
+}
+
+// Get возвращает определение пробы.
+func (m *Manager) Get(target, file string) (Definition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.probes[results.Key{Target: target, File: file}]
+	if !ok {
+		return Definition{}, ErrNotFound
+	}
+	return definition(e), nil
+}
+
+// Create добавляет пробу и, если она не приостановлена, запускает ее. Содержимое объекта пробы генерируется.
+func (m *Manager) Create(def Definition) (Definition, error) {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	e, err := m.newEntry(def, nil)
+	if err != nil {
+		return Definition{}, err
+	}
+	if _, err := m.Get(def.Target, def.File); err == nil {
+		return Definition{}, ErrExists
+	}
+	if err := m.saveState(append(m.List(), definition(e))); err != nil {
+		return Definition{}, err
+	}
+	m.mu.Lock()
+	m.add(e)
+	m.mu.Unlock()
+	m.register(e)
+	if !e.paused {
+		if err := m.start(e); err != nil {
+			return Definition{}, err
+		}
+	}
+	m.cfg.Logger.Info("Probe created", slog.String("target", e.target.Name), slog.String("file", e.probe.FileName))
+	return definition(e), nil
+}
+
+// Update заменяет определение пробы и перезапускает ее. Выполняющаяся итерация завершается со старыми параметрами.
+func (m *Manager) Update(def Definition) (Definition, error) {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	k := results.Key{Target: def.Target, File: def.File}
+	m.mu.Lock()
+	old, ok := m.probes[k]
+	m.mu.Unlock()
+	if !ok {
+		return Definition{}, ErrNotFound
+	}
+	e, err := m.newEntry(def, old.probe)
+	if err != nil {
+		return Definition{}, err
+	}
+	if err := m.saveState(m.replaced(k, definition(e))); err != nil {
+		return Definition{}, err
+	}
+
+	m.stop(old)
+	m.removeFiles(old)
+	m.mu.Lock()
+	m.probes[k] = e
+	m.mu.Unlock()
+	m.register(e)
+	if !e.paused {
+		if err := m.start(e); err != nil {
+			return Definition{}, err
+		}
+	}
+	m.cfg.Logger.Info("Probe updated", slog.String("target", e.target.Name), slog.String("file", e.probe.FileName))
+	return definition(e), nil
+}
+
+// SetPaused приостанавливает пробу или возобновляет ее запуск по расписанию.
+func (m *Manager) SetPaused(target, file string, paused bool) (Definition, error) {
+	def, err := m.Get(target, file)
+	if err != nil {
+		return Definition{}, err
+	}
+	def.Paused = paused
+	return m.Update(def)
+}
+
+// Delete останавливает пробу, удаляет ее объект из бакета, историю выполнений и метрики.
+func (m *Manager) Delete(ctx context.Context, target, file string) error {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	k := results.Key{Target: target, File: file}
+	m.mu.Lock()
+	e, ok := m.probes[k]
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	if err := m.saveState(m.replaced(k, Definition{})); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	delete(m.probes, k)
+	m.order = slices.DeleteFunc(m.order, func(o results.Key) bool { return o == k })
+	m.mu.Unlock()
+	m.remove(ctx, e)
+	m.cfg.Logger.Info("Probe deleted", slog.String("target", target), slog.String("file", file))
+	return nil
+}
+
+// Reload применяет новую конфигурацию cfg. Пробы из конфигурации добавляются, перезапускаются при изменении
+// и удаляются, если их больше нет; неизмененные пробы продолжают работать. Если пробы определяются файлом
+// состояния, сохраняются их определения, а удаляются только пробы удаленных целей. Расписания новых проб
+// проверяются до применения, при ошибке текущие пробы не изменяются. Выполняющиеся итерации измененных
+// и удаленных проб завершаются со старыми параметрами.
+func (m *Manager) Reload(ctx context.Context, cfg *config.Config) error {
+	m.changeMu.Lock()
+	defer m.changeMu.Unlock()
+
+	// Новый набор проб: неизмененные пробы сохраняют свои записи, остальные получают генерируемое содержимое
+	var desired []*entry
+	if m.fromState {
+		targets := make(map[string]*config.Target, len(cfg.Targets))
+		for i := range cfg.Targets {
+			targets[cfg.Targets[i].Name] = &cfg.Targets[i]
+		}
+		for _, e := range m.Entries() {
+			if target, ok := targets[e.Target.Name]; ok {
+				desired = append(desired, &entry{target: target, probe: &e.Probe})
+			}
+		}
+	} else {
+		for _, e := range ConfigEntries(cfg) {
+			probe := e.Probe
+			probe.TempFile, probe.PayloadSeed = "", rand.Uint64()
+			desired = append(desired, &entry{target: e.Target, probe: &probe})
+		}
+	}
+	m.mu.Lock()
+	var started, removed []*entry
+	probes := make(map[results.Key]*entry, len(desired))
+	for i, e := range desired {
+		k := key(e)
+		if _, ok := probes[k]; ok {
+			m.mu.Unlock()
+			return fmt.Errorf("probe %s/%s: %w", k.Target, k.File, ErrExists)
+		}
+		if old, ok := m.probes[k]; ok {
+			if sameProbe(old, e) {
+				desired[i] = old
+				probes[k] = old
+				continue
+			}
+			e.paused = old.paused
+			if old.probe.TempFile == "" && old.probe.FileSizeBytes == e.probe.FileSizeBytes {
+				e.probe.PayloadSeed = old.probe.PayloadSeed
+			}
+		}
+		probes[k] = e
+		started = append(started, e)
+	}
+	for k, e := range m.probes {
+		if _, ok := probes[k]; !ok {
+			removed = append(removed, e)
+		}
+	}
+	m.mu.Unlock()
+	for _, e := range started {
+		if _, err := schedule.New(e.probe); err != nil {
+			return fmt.Errorf("probe %s/%s: %w", e.target.Name, e.probe.FileName, err)
+		}
+	}
+
+	m.mu.Lock()
+	old := m.probes
+	m.cfg = cfg
+	m.probes = probes
+	m.order = m.order[:0]
+	for _, e := range desired {
+		m.order = append(m.order, key(e))
+	}
+	m.mu.Unlock()
+	for _, e := range removed {
+		m.remove(ctx, e)
+	}
+	for _, e := range started {
+		k := key(e)
+		if prev, ok := old[k]; ok {
+			m.stop(prev)
+			if prev.probe.TempFile != e.probe.TempFile {
+				m.removeFiles(prev)
+			}
+		}
+		m.register(e)
+		if !e.paused {
+			if err := m.start(e); err != nil {
+				return fmt.Errorf("probe %s/%s: %w", k.Target, k.File, err)
+			}
+		}
+	}
+	if m.fromState && len(removed) > 0 {
+		if err := m.saveState(m.List()); err != nil {
+			m.cfg.Logger.Warn("Failed to save probes state", slog.Any("error", err))
+		}
+	}
+	m.cfg.Logger.Info("Probes reloaded", slog.Int("probes", len(desired)), slog.Int("restarted", len(started)), slog.Int("removed", len(removed)))
+	return nil
+}
+
+// remove останавливает удаленную из списка пробу и удаляет ее объект из бакета, историю выполнений и метрики.
+// Вызывается под changeMu.
+func (m *Manager) remove(ctx context.Context, e *entry) {
+	k := key(e)
+	m.stop(e)
+	m.store.Unregister(k)
+	metrics.DeleteProbe(k.Target, k.File)
+	if err := s3lib.CleanupProbe(ctx, m.cfg, e.target, e.probe); err != nil {
+		m.cfg.Logger.Warn("Failed to clean up probe objects", slog.String("target", k.Target), slog.String("file", k.File), slog.Any("error", err))
+	}
+	m.removeFiles(e)
+}
+
+// sameProbe сообщает, совпадают ли параметры проб a и b и их целей, кроме содержимого объекта.
+func sameProbe(a, b *entry) bool {
+	targetA, targetB := *a.target, *b.target
+	targetA.Probes, targetB.Probes = nil, nil
+	probeA, probeB := *a.probe, *b.probe
+	probeA.TempFile, probeB.TempFile = "", ""
+	probeA.PayloadSeed, probeB.PayloadSeed = 0, 0
+	return reflect.DeepEqual(targetA, targetB) && reflect.DeepEqual(probeA, probeB)
+}
+
+func key(e *entry) results.Key {
+	return results.Key{Target: e.target.Name, File: e.probe.FileName}
+}
+
+// register добавляет пробу в хранилище результатов и публикует ее метки. Приостановленная проба удаляется
+// из хранилища вместе с историей выполнений: она не выполняется и не должна учитываться в readiness.
+func (m *Manager) register(e *entry) {
+	k := key(e)
+	if e.paused {
+		m.store.Unregister(k)
+		return
+	}
+	m.store.Register(k)
+	m.store.SetLabels(k, e.probe.Labels)
+	metrics.SetProbeLabels(k.Target, k.File, e.probe.Labels)
+}
+
+func (m *Manager) add(e *entry) {
+	m.probes[key(e)] = e
+	m.order = append(m.order, key(e))
+}
+
+// start запускает цикл пробы по расписанию. Вызывается под changeMu.
+func (m *Manager) start(e *entry) error {
+	if m.ctx == nil || m.stopped {
+		return nil
+	}
+	sched, err := schedule.New(e.probe)
+	if err != nil {
+		return err
+	}
+	k := key(e)
+	m.wd.Register(k, time.Duration(m.cfg.LivenessMultiplier)*sched.Period()+e.probe.ScenarioTimeout())
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	e.stop, e.done = cancel, make(chan struct{})
+	m.loops.Add(1)
+	go func(done chan struct{}) {
+		defer m.loops.Done()
+		defer close(done)
+		sched.Run(ctx, e.target, e.probe, func() { m.iterate(e.target, e.probe) })
+	}(e.done)
+	return nil
+}
+
+// stop останавливает цикл пробы и ждет завершения выполняющейся итерации. Вызывается под changeMu.
+func (m *Manager) stop(e *entry) {
+	if e.stop == nil {
+		return
+	}
+	e.stop()
+	<-e.done
+	e.stop = nil
+	m.wd.Unregister(key(e))
+}
+
+// removeFiles удаляет локальный файл пробы, созданный при загрузке конфигурации, и скачанный файл.
+func (m *Manager) removeFiles(e *entry) {
+	for _, path := range []string{e.probe.TempFile, e.probe.DownloadPath(e.target.FilesDir)} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			m.cfg.Logger.Warn("Failed to remove temporary file", slog.String("file", path), slog.Any("error", err))
+		}
+	}
+}
+
+// newEntry проверяет определение и создает по нему пробу с генерируемым содержимым. Если задана
+// предыдущая версия пробы old с тем же размером, сохраняется ее содержимое.
+func (m *Manager) newEntry(def Definition, old *config.Probe) (*entry, error) {
+	var target *config.Target
+	for i := range m.cfg.Targets {
+		if m.cfg.Targets[i].Name == def.Target {
+			target = &m.cfg.Targets[i]
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("unknown target %q", def.Target)
+	}
+	if def.File == "" || strings.Contains(def.File, "/") {
+		return nil, fmt.Errorf("file must be a non-empty name without '/'")
+	}
+	probe := &config.Probe{
+		FileName:       def.File,
+		MaxRetries:     config.DefaultMaxRetries,
+		RetryMinDelay:  config.DefaultRetryMinDelay,
+		RetryMaxDelay:  config.DefaultRetryMaxDelay,
+		RetryableCodes: def.RetryableCodes,
+		Scenario:       def.Scenario,
+		Labels:         def.Labels,
+		PayloadSeed:    rand.Uint64(),
+	}
+	if def.SizeBytes != "" {
+		size, err := config.ParseSize(string(def.SizeBytes))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("size_bytes must be a non-negative size, got %q", def.SizeBytes)
+		}
+		probe.FileSizeBytes = size
+	}
+	for _, field := range []struct {
+		name     string
+		value    config.Value
+		unit     time.Duration
+		required bool
+		dst      *time.Duration
+	}{
+		{"upload_timeout_seconds", def.UploadTimeout, time.Second, true, &probe.UploadTimeout},
+		{"download_timeout_seconds", def.DownloadTimeout, time.Second, true, &probe.DownloadTimeout},
+		{"delete_timeout_seconds", def.DeleteTimeout, time.Second, true, &probe.DeleteTimeout},
+		{"jitter_seconds", def.Jitter, time.Second, false, &probe.Jitter},
+		{"retry_min_delay_ms", def.RetryMinDelay, time.Millisecond, false, &probe.RetryMinDelay},
+		{"retry_max_delay_ms", def.RetryMaxDelay, time.Millisecond, false, &probe.RetryMaxDelay},
+	} {
+		if field.value == "" && !field.required {
+			continue
+		}
+		duration, err := config.ParseDuration(string(field.value), field.unit)
+		switch {
+		case field.required && (err != nil || duration <= 0):
+			return nil, fmt.Errorf("%s must be a positive duration, got %q", field.name, field.value)
+		case err != nil || duration < 0:
+			return nil, fmt.Errorf("%s must be a non-negative duration, got %q", field.name, field.value)
+		case duration > 0:
+			// Нулевые задержки повторов означают значения по умолчанию
+			*field.dst = duration
+		}
+	}
+	if def.MaxRetries != nil {
+		if probe.MaxRetries = *def.MaxRetries; probe.MaxRetries < 0 {
+			return nil, errors.New("max_retries must not be negative")
+		}
+	}
+	if err := config.ValidateScenario(def.Scenario); err != nil {
+		return nil, fmt.Errorf("scenario: %w", err)
+	}
+	var err error
+	if probe.Interval, probe.Cron, err = config.ParseSchedule(def.Schedule, m.cfg.TaskInterval); err != nil {
+		return nil, err
+	}
+	if old != nil && old.TempFile == "" && old.FileSizeBytes == probe.FileSizeBytes {
+		probe.PayloadSeed = old.PayloadSeed
+	}
+	return &entry{target: target, probe: probe, paused: def.Paused}, nil
+}
+
+func definition(e *entry) Definition {
+	probe := e.probe
+	schedule := probe.Cron
+	if schedule == "" {
+		schedule = config.FormatDuration(probe.Interval, time.Second)
+	}
+	var jitter config.Value
+	if probe.Jitter > 0 {
+		jitter = config.Value(config.FormatDuration(probe.Jitter, time.Second))
+	}
+	maxRetries := probe.MaxRetries
+	return Definition{
+		Target:          e.target.Name,
+		File:            probe.FileName,
+		SizeBytes:       config.Value(strconv.Itoa(probe.FileSizeBytes)),
+		UploadTimeout:   config.Value(config.FormatDuration(probe.UploadTimeout, time.Second)),
+		DownloadTimeout: config.Value(config.FormatDuration(probe.DownloadTimeout, time.Second)),
+		DeleteTimeout:   config.Value(config.FormatDuration(probe.DeleteTimeout, time.Second)),
+		Schedule:        schedule,
+		Jitter:          jitter,
+		MaxRetries:      &maxRetries,
+		RetryMinDelay:   config.Value(config.FormatDuration(probe.RetryMinDelay, time.Millisecond)),
+		RetryMaxDelay:   config.Value(config.FormatDuration(probe.RetryMaxDelay, time.Millisecond)),
+		RetryableCodes:  probe.RetryableCodes,
+		Scenario:        probe.Scenario,
+		Labels:          probe.Labels,
+		Paused:          e.paused,
+	}
+}
+
+// definitions возвращает определения всех проб. Вызывается под mu.
+func (m *Manager) definitions() []Definition {
+	definitions := make([]Definition, 0, len(m.order))
+	for _, k := range m.order {
+		definitions = append(definitions, definition(m.probes[k]))
+	}
+	return definitions
+}
+
+// replaced возвращает определения всех проб, в которых проба k заменена на def или, если def пусто, удалена.
+func (m *Manager) replaced(k results.Key, def Definition) []Definition {
+	var definitions []Definition
+	for _, d := range m.List() {
+		switch {
+		case d.Target != k.Target || d.File != k.File:
+			definitions = append(definitions, d)
+		case def.File != "":
+			definitions = append(definitions, def)
+		}
+	}
+	return definitions
+}
+
+// loadState читает определения проб из файла состояния. Возвращает nil, если файл не задан или не существует.
+func (m *Manager) loadState() ([]Definition, error) {
+	if m.stateFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(m.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	definitions := []Definition{}
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("state file %s: %w", m.stateFile, err)
+	}
+	return definitions, nil
+}
+
+// saveState записывает определения проб в файл состояния через временный файл, чтобы при сбое
+// не остался частично записанный файл. После записи пробы определяются файлом состояния. Вызывается под changeMu.
+func (m *Manager) saveState(definitions []Definition) error {
+	if m.stateFile == "" {
+		return nil
+	}
+	if definitions == nil {
+		definitions = []Definition{}
+	}
+	data, err := json.MarshalIndent(definitions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("%w: %v", errSaveState, err)
+	}
+	if err := os.Rename(tmp, m.stateFile); err != nil {
+		return fmt.Errorf("%w: %v", errSaveState, err)
+	}
+	m.fromState = true
+	return nil
+}
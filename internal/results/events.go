@@ -0,0 +1,111 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventBuffer - число событий, которые могут ожидать отправки подписчику. Если подписчик
+// не успевает их читать, новые события для него отбрасываются.
+const eventBuffer = 64
+
+// keepaliveInterval - интервал отправки комментариев, не дающих прокси закрыть простаивающее соединение.
+const keepaliveInterval = 15 * time.Second
+
+// Event - событие о завершении шага сценария пробы.
+type Event struct {
+	Target string `json:"target"`
+	File   string `json:"file"`
+	Step
+}
+
+// EventStream рассылает события о завершенных шагах подписчикам /events.
+type EventStream struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewEventStream создает новый экземпляр EventStream.
+func NewEventStream() *EventStream {
+	return &EventStream{subscribers: make(map[chan Event]struct{}), closed: make(chan struct{})}
+}
+
+// Close завершает все подключения к /events, чтобы остановка HTTP сервера не ждала их отключения.
+func (e *EventStream) Close() {
+	e.closeOnce.Do(func() { close(e.closed) })
+}
+
+// StepStarted ничего не делает: события отправляются только о завершенных шагах.
+func (e *EventStream) StepStarted(Key, string) {}
+
+// StepFinished отправляет событие о завершении шага всем подписчикам.
+func (e *EventStream) StepFinished(key Key, step Step) {
+	event := Event{Target: key.Target, File: key.File, Step: step}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (e *EventStream) subscribe() chan Event {
+	ch := make(chan Event, eventBuffer)
+	e.mu.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mu.Unlock()
+	return ch
+}
+
+func (e *EventStream) unsubscribe(ch chan Event) {
+	e.mu.Lock()
+	delete(e.subscribers, ch)
+	e.mu.Unlock()
+}
+
+// HandleEvents передает события о завершенных шагах в формате Server-Sent Events, пока клиент не отключится.
+func (e *EventStream) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := e.subscribe()
+	defer e.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-e.closed:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-ch:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: step\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,221 @@
+package results
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Результаты шага сценария.
+const (
+	OutcomeOK              = "ok"
+	OutcomeError           = "error"
+	OutcomeTimeout         = "timeout"
+	OutcomeIntegrityFailed = "integrity_failed"
+)
+
+// Step содержит результат одной операции сценария пробы.
+type Step struct {
+	Operation  string    `json:"operation"`
+	Started    time.Time `json:"started"`
+	Duration   float64   `json:"duration_seconds"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// Run содержит результат одного выполнения сценария пробы.
+type Run struct {
+	Target   string    `json:"target"`
+	File     string    `json:"file"`
+	Size     int       `json:"size_bytes,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Steps    []Step    `json:"steps"`
+	OK       bool      `json:"ok"`
+}
+
+// Failure возвращает первый неуспешный шаг выполнения или nil, если сценарий прошел успешно.
+func (r *Run) Failure() *Step {
+	for i := range r.Steps {
+		if r.Steps[i].Outcome != OutcomeOK {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Key идентифицирует пробу: цель и файл.
+type Key struct {
+	Target string
+	File   string
+}
+
+// Observer получает уведомления о ходе выполнения сценария пробы.
+type Observer interface {
+	StepStarted(key Key, operation string)
+	StepFinished(key Key, step Step)
+}
+
+// Observers рассылает уведомления о ходе выполнения сценария нескольким наблюдателям.
+type Observers []Observer
+
+// StepStarted уведомляет всех наблюдателей о начале шага.
+func (o Observers) StepStarted(key Key, operation string) {
+	for _, observer := range o {
+		observer.StepStarted(key, operation)
+	}
+}
+
+// StepFinished уведомляет всех наблюдателей о завершении шага.
+func (o Observers) StepFinished(key Key, step Step) {
+	for _, observer := range o {
+		observer.StepFinished(key, step)
+	}
+}
+
+// OperationStatus содержит последние результаты операции пробы.
+type OperationStatus struct {
+	LastOutcome    string    `json:"last_outcome"`
+	LastStarted    time.Time `json:"last_started"`
+	LastDuration   float64   `json:"last_duration_seconds"`
+	LastSuccessAt  time.Time `json:"last_success_at,omitzero"`
+	LastFailureAt  time.Time `json:"last_failure_at,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastErrorClass string    `json:"last_error_class,omitempty"`
+}
+
+// Store хранит последние выполнения сценария каждой пробы.
+type Store struct {
+	mu         sync.RWMutex
+	history    int
+	runs       map[Key][]Run
+	operations map[Key]map[string]*OperationStatus
+	labels     map[Key]map[string]string
+	order      []Key
+}
+
+// NewStore создает хранилище, которое помнит history последних выполнений каждой пробы.
+func NewStore(history int) *Store {
+	if history < 1 {
+		history = 1
+	}
+	return &Store{
+		history:    history,
+		runs:       make(map[Key][]Run),
+		operations: make(map[Key]map[string]*OperationStatus),
+		labels:     make(map[Key]map[string]string),
+	}
+}
+
+// Register добавляет пробу, еще не имеющую выполнений, чтобы она учитывалась в проверках.
+func (s *Store) Register(key Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.runs[key]; !ok {
+		s.runs[key] = nil
+		s.order = append(s.order, key)
+	}
+}
+
+// SetLabels задает метки пробы, которые выводятся в /status.
+func (s *Store) SetLabels(key Key, labels map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.labels[key] = labels
+}
+
+// Labels возвращает метки пробы.
+func (s *Store) Labels(key Key) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.labels[key]
+}
+
+// Unregister удаляет пробу и историю ее выполнений.
+func (s *Store) Unregister(key Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.runs, key)
+	delete(s.operations, key)
+	delete(s.labels, key)
+	s.order = slices.DeleteFunc(s.order, func(k Key) bool { return k == key })
+}
+
+// Record сохраняет выполнение сценария, вытесняя самое старое, если история заполнена.
+func (s *Store) Record(run Run) {
+	key := Key{Target: run.Target, File: run.File}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, ok := s.runs[key]
+	if !ok {
+		s.order = append(s.order, key)
+	}
+	runs = append(runs, run)
+	if len(runs) > s.history {
+		runs = runs[len(runs)-s.history:]
+	}
+	s.runs[key] = runs
+
+	operations, ok := s.operations[key]
+	if !ok {
+		operations = make(map[string]*OperationStatus)
+		s.operations[key] = operations
+	}
+	for _, step := range run.Steps {
+		status, ok := operations[step.Operation]
+		if !ok {
+			status = &OperationStatus{}
+			operations[step.Operation] = status
+		}
+		status.LastOutcome = step.Outcome
+		status.LastStarted = step.Started
+		status.LastDuration = step.Duration
+		if step.Outcome == OutcomeOK {
+			status.LastSuccessAt = step.Started
+		} else {
+			status.LastFailureAt = step.Started
+			status.LastError = step.Error
+			status.LastErrorClass = step.ErrorClass
+		}
+	}
+}
+
+// Keys возвращает пробы в порядке регистрации.
+func (s *Store) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Key(nil), s.order...)
+}
+
+// Last возвращает до n последних выполнений пробы, от старых к новым.
+func (s *Store) Last(key Key, n int) []Run {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	runs := s.runs[key]
+	if n > 0 && len(runs) > n {
+		runs = runs[len(runs)-n:]
+	}
+	return append([]Run(nil), runs...)
+}
+
+// Operations возвращает последние результаты операций пробы.
+func (s *Store) Operations(key Key) map[string]OperationStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	operations := make(map[string]OperationStatus, len(s.operations[key]))
+	for operation, status := range s.operations[key] {
+		operations[operation] = *status
+	}
+	return operations
+}
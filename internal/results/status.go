@@ -0,0 +1,52 @@
+package results
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ProbeStatus - состояние пробы в ответе /status.
+type ProbeStatus struct {
+	Target     string                     `json:"target"`
+	File       string                     `json:"file"`
+	Labels     map[string]string          `json:"labels,omitempty"`
+	LastRun    *Run                       `json:"last_run,omitempty"`
+	Operations map[string]OperationStatus `json:"operations"`
+	History    []Run                      `json:"history"`
+}
+
+// StatusReport - тело ответа /status.
+type StatusReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Probes      []ProbeStatus `json:"probes"`
+}
+
+// Status возвращает состояние всех проб с историей последних выполнений.
+func (s *Store) Status() StatusReport {
+	report := StatusReport{GeneratedAt: time.Now(), Probes: []ProbeStatus{}}
+	for _, key := range s.Keys() {
+		probe := ProbeStatus{
+			Target:     key.Target,
+			File:       key.File,
+			Labels:     s.Labels(key),
+			Operations: s.Operations(key),
+			History:    s.Last(key, 0),
+		}
+		if len(probe.History) > 0 {
+			probe.LastRun = &probe.History[len(probe.History)-1]
+		} else {
+			probe.History = []Run{}
+		}
+		report.Probes = append(report.Probes, probe)
+	}
+	return report
+}
+
+// HandleStatus возвращает состояние проб в формате JSON.
+func (s *Store) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s.Status())
+}
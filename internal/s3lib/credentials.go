@@ -0,0 +1,208 @@
+package s3lib
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"s3syn-test/internal/config"
+)
+
+// Учетные данные создаются один раз на цель, чтобы провайдеры кэшировали
+// полученные ключи и не обращались к STS или внешнему процессу при каждой операции.
+// Кэш хранит по одной записи на имя цели: при перезагрузке конфигурации цель получает новый *config.Target,
+// поэтому запись заменяется, только если изменились параметры учетных данных.
+var (
+	credentialsMu    sync.Mutex
+	credentialsCache = map[string]cachedCredentials{}
+)
+
+type cachedCredentials struct {
+	settings credentialsSettings
+	creds    *credentials.Credentials
+}
+
+// credentialsSettings - параметры цели, от которых зависят ее учетные данные.
+type credentialsSettings struct {
+	region          string
+	accessKey       string
+	secretKey       string
+	provider        string
+	accessKeyFile   string
+	secretKeyFile   string
+	sharedFile      string
+	profile         string
+	roleARN         string
+	roleSessionName string
+	externalID      string
+	tokenFile       string
+	stsEndpoint     string
+	process         string
+}
+
+func settingsOf(target *config.Target) credentialsSettings {
+	return credentialsSettings{
+		region:          target.S3Region,
+		accessKey:       target.S3AccessKey,
+		secretKey:       target.S3SecretKey,
+		provider:        target.CredentialsProvider,
+		accessKeyFile:   target.S3AccessKeyFile,
+		secretKeyFile:   target.S3SecretKeyFile,
+		sharedFile:      target.SharedCredentialsFile,
+		profile:         target.Profile,
+		roleARN:         target.RoleARN,
+		roleSessionName: target.RoleSessionName,
+		externalID:      target.ExternalID,
+		tokenFile:       target.WebIdentityTokenFile,
+		stsEndpoint:     target.STSEndpoint,
+		process:         target.CredentialProcess,
+	}
+}
+
+// TargetCredentials возвращает учетные данные цели в соответствии с настроенным провайдером.
+func TargetCredentials(cfg *config.Config, target *config.Target) (*credentials.Credentials, error) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	settings := settingsOf(target)
+	if cached, ok := credentialsCache[target.Name]; ok && cached.settings == settings {
+		return cached.creds, nil
+	}
+	creds, err := newCredentials(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	credentialsCache[target.Name] = cachedCredentials{settings: settings, creds: creds}
+	return creds, nil
+}
+
+func newCredentials(cfg *config.Config, target *config.Target) (*credentials.Credentials, error) {
+	switch target.CredentialsProvider {
+	case config.CredentialsStatic, "":
+		return staticCredentials(target), nil
+	case config.CredentialsShared:
+		return credentials.NewSharedCredentials(target.SharedCredentialsFile, target.Profile), nil
+	case config.CredentialsProcess:
+		return processcreds.NewCredentials(target.CredentialProcess), nil
+	case config.CredentialsWebIdentity:
+		sess, err := stsSession(cfg, target, credentials.AnonymousCredentials)
+		if err != nil {
+			return nil, err
+		}
+		return stscreds.NewWebIdentityCredentials(sess, target.RoleARN, target.RoleSessionName, target.WebIdentityTokenFile), nil
+	case config.CredentialsAssumeRole:
+		sess, err := stsSession(cfg, target, staticCredentials(target))
+		if err != nil {
+			return nil, err
+		}
+		return stscreds.NewCredentials(sess, target.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = target.RoleSessionName
+			if target.ExternalID != "" {
+				p.ExternalID = aws.String(target.ExternalID)
+			}
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown credentials provider %q", target.CredentialsProvider)
+	}
+}
+
+// staticCredentials возвращает ключи из переменных окружения или, если заданы пути к файлам, из файлов секрета.
+func staticCredentials(target *config.Target) *credentials.Credentials {
+	if target.S3AccessKeyFile == "" && target.S3SecretKeyFile == "" {
+		return credentials.NewStaticCredentials(target.S3AccessKey, target.S3SecretKey, "")
+	}
+	return credentials.NewCredentials(&fileProvider{
+		accessKey:     target.S3AccessKey,
+		secretKey:     target.S3SecretKey,
+		accessKeyFile: target.S3AccessKeyFile,
+		secretKeyFile: target.S3SecretKeyFile,
+	})
+}
+
+// stsSession создает сессию для обращения к STS цели.
+func stsSession(cfg *config.Config, target *config.Target, creds *credentials.Credentials) (*session.Session, error) {
+	awsCfg := &aws.Config{
+		Region:      aws.String(target.S3Region),
+		Credentials: creds,
+		HTTPClient:  newHTTPClient(),
+		LogLevel:    aws.LogLevel(cfg.AwsLogLevel),
+	}
+	if target.STSEndpoint != "" {
+		awsCfg.Endpoint = aws.String(target.STSEndpoint)
+	}
+	return session.NewSession(awsCfg)
+}
+
+const fileProviderName = "SecretFileProvider"
+
+// fileProvider читает ключи из файлов смонтированного секрета и перечитывает их,
+// когда Kubernetes обновляет секрет (меняется время модификации файла).
+type fileProvider struct {
+	accessKey     string
+	secretKey     string
+	accessKeyFile string
+	secretKeyFile string
+
+	mu       sync.Mutex
+	modTimes [2]time.Time
+}
+
+// Retrieve читает ключи из файлов. Если путь к файлу не задан, используется значение из переменной окружения.
+func (p *fileProvider) Retrieve() (credentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	accessKey, accessModTime, err := readSecretFile(p.accessKeyFile, p.accessKey)
+	if err != nil {
+		return credentials.Value{ProviderName: fileProviderName}, err
+	}
+	secretKey, secretModTime, err := readSecretFile(p.secretKeyFile, p.secretKey)
+	if err != nil {
+		return credentials.Value{ProviderName: fileProviderName}, err
+	}
+	p.modTimes = [2]time.Time{accessModTime, secretModTime}
+	return credentials.Value{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		ProviderName:    fileProviderName,
+	}, nil
+}
+
+// IsExpired сообщает, что ключи нужно перечитать, если один из файлов изменился.
+func (p *fileProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, path := range []string{p.accessKeyFile, p.secretKeyFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(p.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSecretFile(path, fallback string) (string, time.Time, error) {
+	if path == "" {
+		return fallback, time.Time{}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return strings.TrimSpace(string(data)), info.ModTime(), nil
+}
@@ -0,0 +1,134 @@
+package s3lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/payload"
+)
+
+// deleteObjectsBatch - максимальное число ключей в одном запросе DeleteObjects.
+const deleteObjectsBatch = 1000
+
+// GetObject читает объект пробы, не сохраняя его, и возвращает число прочитанных байт.
+// Размер объекта должен совпадать с размером пробы, а сгенерированное содержимое - с содержимым пробы.
+func GetObject(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, opts ...request.Option) (n int64, err error) {
+	var retries retryObserver
+	defer func() { retries.record(target, probe.FileName, "get", err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, probe.DownloadTimeout)
+	defer cancel()
+
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := NewS3Client(sess, target).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(target.S3Bucket),
+		Key:    aws.String(probe.ObjectKey()),
+	}, retries.requestOptions(probe, opts...)...)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body := &countingReader{r: resp.Body}
+	if probe.TempFile == "" {
+		err = payload.Verify(body, probe.PayloadSeed, int64(probe.FileSizeBytes))
+	} else {
+		_, err = io.Copy(io.Discard, body)
+		if err == nil && body.n != int64(probe.FileSizeBytes) {
+			err = fmt.Errorf("%w: read %d bytes, expected %d", payload.ErrMismatch, body.n, probe.FileSizeBytes)
+		}
+	}
+	if errors.Is(err, payload.ErrMismatch) {
+		err = fmt.Errorf("%w: %v", ErrIntegrityCheckFailed, err)
+	}
+	return body.n, err
+}
+
+// HeadObject запрашивает метаданные объекта пробы.
+func HeadObject(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, opts ...request.Option) (err error) {
+	var retries retryObserver
+	defer func() { retries.record(target, probe.FileName, "head", err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, probe.DownloadTimeout)
+	defer cancel()
+
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return err
+	}
+	_, err = NewS3Client(sess, target).HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(target.S3Bucket),
+		Key:    aws.String(probe.ObjectKey()),
+	}, retries.requestOptions(probe, opts...)...)
+	return err
+}
+
+// ListObjects запрашивает первую страницу списка объектов с префиксом prefix и возвращает число объектов в ней.
+func ListObjects(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, prefix string, opts ...request.Option) (count int, err error) {
+	var retries retryObserver
+	defer func() { retries.record(target, probe.FileName, "list", err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, probe.DownloadTimeout)
+	defer cancel()
+
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := NewS3Client(sess, target).ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(target.S3Bucket),
+		Prefix: aws.String(prefix),
+	}, retries.requestOptions(probe, opts...)...)
+	if err != nil {
+		return 0, err
+	}
+	return len(resp.Contents), nil
+}
+
+// DeleteObjects удаляет объекты с ключами keys запросами DeleteObjects по 1000 ключей.
+func DeleteObjects(ctx context.Context, cfg *config.Config, target *config.Target, keys []string) error {
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return err
+	}
+	svc := NewS3Client(sess, target)
+	for start := 0; start < len(keys); start += deleteObjectsBatch {
+		batch := keys[start:min(start+deleteObjectsBatch, len(keys))]
+		objects := make([]*s3.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		resp, err := svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(target.S3Bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Errors) > 0 {
+			return fmt.Errorf("failed to delete %d objects, first: %s: %s", len(resp.Errors), aws.StringValue(resp.Errors[0].Key), aws.StringValue(resp.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// countingReader подсчитывает прочитанные байты.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
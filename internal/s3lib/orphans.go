@@ -0,0 +1,106 @@
+package s3lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/metrics"
+)
+
+// OrphanStats - результат очистки объектов, оставшихся после прерванных сценариев.
+type OrphanStats struct {
+	Objects int
+	Bytes   int64
+	Uploads int
+}
+
+// CleanupOrphans удаляет объекты цели с ключами, начинающимися с одного из prefixes и подходящими под match
+// (nil - все ключи под префиксами), которые изменены раньше olderThan, и прерывает такие же multipart upload,
+// начатые раньше olderThan. Объекты удаляются пакетами DeleteObjects. С dryRun объекты и upload только подсчитываются.
+func CleanupOrphans(ctx context.Context, cfg *config.Config, target *config.Target, prefixes []string, match func(key string) bool, olderThan time.Time, dryRun bool) (stats OrphanStats, err error) {
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return stats, err
+	}
+	svc := NewS3Client(sess, target)
+
+	// С dryRun найденные объекты записываются в лог как список для проверки
+	level := slog.LevelDebug
+	if dryRun {
+		level = slog.LevelInfo
+	}
+
+	// Префиксы могут пересекаться, поэтому ключи собираются без повторов
+	seen := make(map[string]bool)
+	var keys []string
+	for _, prefix := range prefixes {
+		err = svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(target.S3Bucket),
+			Prefix: aws.String(prefix),
+		}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+			for _, object := range page.Contents {
+				key := aws.StringValue(object.Key)
+				if seen[key] || (match != nil && !match(key)) || !aws.TimeValue(object.LastModified).Before(olderThan) {
+					continue
+				}
+				seen[key] = true
+				keys = append(keys, key)
+				stats.Bytes += aws.Int64Value(object.Size)
+				cfg.Logger.Log(ctx, level, "Orphaned object", slog.String("target", target.Name), slog.String("key", key), slog.Time("last_modified", aws.TimeValue(object.LastModified)))
+			}
+			return true
+		})
+		if err != nil {
+			return stats, fmt.Errorf("list objects %q: %w", prefix, err)
+		}
+	}
+	stats.Objects = len(keys)
+	if !dryRun && len(keys) > 0 {
+		if err = DeleteObjects(ctx, cfg, target, keys); err != nil {
+			return stats, fmt.Errorf("delete objects: %w", err)
+		}
+		metrics.CleanupObjects.WithLabelValues(target.Name, target.S3Endpoint, target.S3Bucket).Add(float64(len(keys)))
+	}
+
+	seen = make(map[string]bool)
+	for _, prefix := range prefixes {
+		var uploads []*s3.MultipartUpload
+		err = svc.ListMultipartUploadsPagesWithContext(ctx, &s3.ListMultipartUploadsInput{
+			Bucket: aws.String(target.S3Bucket),
+			Prefix: aws.String(prefix),
+		}, func(page *s3.ListMultipartUploadsOutput, _ bool) bool {
+			uploads = append(uploads, page.Uploads...)
+			return true
+		})
+		if err != nil {
+			return stats, fmt.Errorf("list multipart uploads %q: %w", prefix, err)
+		}
+		for _, upload := range uploads {
+			uploadID := aws.StringValue(upload.UploadId)
+			if seen[uploadID] || (match != nil && !match(aws.StringValue(upload.Key))) || !aws.TimeValue(upload.Initiated).Before(olderThan) {
+				continue
+			}
+			seen[uploadID] = true
+			stats.Uploads++
+			if dryRun {
+				continue
+			}
+			_, err = svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(target.S3Bucket),
+				Key:      upload.Key,
+				UploadId: upload.UploadId,
+			})
+			if err != nil {
+				return stats, fmt.Errorf("abort multipart upload %s: %w", uploadID, err)
+			}
+			metrics.CleanupUploads.WithLabelValues(target.Name, target.S3Endpoint, target.S3Bucket).Inc()
+			cfg.Logger.Info("Aborted stale multipart upload", slog.String("target", target.Name), slog.String("key", aws.StringValue(upload.Key)), slog.String("upload_id", uploadID))
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,101 @@
+package s3lib
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/metrics"
+)
+
+// probeRetryer применяет политику повторов пробы. Если заданы коды ошибок, повторяются
+// только запросы, завершившиеся ошибкой с одним из этих кодов или HTTP статусов.
+type probeRetryer struct {
+	client.DefaultRetryer
+	codes map[string]bool
+}
+
+func newProbeRetryer(probe *config.Probe) probeRetryer {
+	r := probeRetryer{
+		DefaultRetryer: client.DefaultRetryer{
+			NumMaxRetries:    probe.MaxRetries,
+			MinRetryDelay:    probe.RetryMinDelay,
+			MaxRetryDelay:    probe.RetryMaxDelay,
+			MinThrottleDelay: client.DefaultRetryerMinThrottleDelay,
+			MaxThrottleDelay: probe.RetryMaxDelay,
+		},
+	}
+	if len(probe.RetryableCodes) > 0 {
+		r.codes = make(map[string]bool, len(probe.RetryableCodes))
+		for _, code := range probe.RetryableCodes {
+			r.codes[code] = true
+		}
+	}
+	return r
+}
+
+// ShouldRetry сообщает, нужно ли повторить запрос.
+func (r probeRetryer) ShouldRetry(req *request.Request) bool {
+	if r.codes == nil {
+		return r.DefaultRetryer.ShouldRetry(req)
+	}
+	if aerr, ok := req.Error.(awserr.Error); ok && r.codes[aerr.Code()] {
+		return true
+	}
+	return req.HTTPResponse != nil && r.codes[strconv.Itoa(req.HTTPResponse.StatusCode)]
+}
+
+// retryObserver подсчитывает повторы всех запросов операции, включая запросы частей multipart upload.
+type retryObserver struct {
+	retries atomic.Int64
+}
+
+// requestOptions возвращает опции запросов операции: политику повторов пробы, подсчет повторов
+// и дополнительные опции opts.
+func (o *retryObserver) requestOptions(probe *config.Probe, opts ...request.Option) []request.Option {
+	retryer := newProbeRetryer(probe)
+	return append([]request.Option{func(r *request.Request) {
+		r.Retryer = retryer
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			o.retries.Add(int64(r.RetryCount))
+		})
+	}}, opts...)
+}
+
+// record обновляет метрики повторов операции.
+func (o *retryObserver) record(target *config.Target, fileName, operation string, err error) {
+	retries := o.retries.Load()
+	metrics.Retries.WithLabelValues(labels(target, fileName, operation)...).Add(float64(retries))
+	firstAttemptSuccess := 0.0
+	if err == nil && retries == 0 {
+		firstAttemptSuccess = 1
+	}
+	metrics.FirstAttemptSuccess.WithLabelValues(labels(target, fileName, operation)...).Set(firstAttemptSuccess)
+}
+
+// requestIDRecorder запоминает ID последнего завершенного запроса операции.
+type requestIDRecorder struct {
+	mu sync.Mutex
+	id string
+}
+
+func (o *requestIDRecorder) option(r *request.Request) {
+	r.Handlers.Complete.PushBack(func(r *request.Request) {
+		if r.RequestID == "" {
+			return
+		}
+		o.mu.Lock()
+		o.id = r.RequestID
+		o.mu.Unlock()
+	})
+}
+
+func (o *requestIDRecorder) get() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.id
+}
@@ -5,153 +5,206 @@ import (
 	"crypto/md5"
 	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"log/slog"
 	"s3syn-test/internal/config"
 	"s3syn-test/internal/metrics"
+	"s3syn-test/internal/payload"
+	"s3syn-test/internal/results"
 )
 
-func ProcessFile(cfg *config.Config, localFilePath, fileName string, fileSize, uploadTimeout, downloadTimeout, deleteTimeout int) {
-	err := UploadFileToS3(cfg, localFilePath, fileName, fileSize, uploadTimeout)
+// ErrIntegrityCheckFailed возвращается, если хеш скачанного файла не совпадает с исходным.
+var ErrIntegrityCheckFailed = errors.New("file integrity check failed")
+
+// ProcessFile выполняет сценарий пробы: загрузку, скачивание, проверку целостности и удаление файла
+// или, если он задан, собственный сценарий пробы.
+// Observer, если задан, получает уведомления о начале и завершении каждого шага.
+// Отмена ctx прерывает выполняемую операцию, оставшиеся шаги не выполняются.
+func ProcessFile(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, observer results.Observer) (run results.Run) {
+	if len(probe.Scenario) > 0 {
+		return RunScenario(ctx, cfg, target, probe, probe.Scenario, observer)
+	}
+	localFilePath, fileName := probe.TempFile, probe.FileName
+	run = results.Run{Target: target.Name, File: fileName, Size: probe.FileSizeBytes, Started: time.Now()}
+	step := func(operation string, fn func(opt request.Option) error) error {
+		return RunStep(&run, observer, operation, fn)
+	}
+	defer func() {
+		run.Finished = time.Now()
+		run.OK = run.Failure() == nil
+	}()
+
+	err := step("upload", func(opt request.Option) error { return UploadFileToS3(ctx, cfg, target, probe, opt) })
 	if err != nil {
-		cfg.Logger.Error("Upload failed", slog.String("file", fileName), slog.Any("error", err))
-		return
+		cfg.Logger.Error("Upload failed", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		return run
 	}
 
-	downloadedFilePath, err := DownloadFileFromS3(cfg, fileName, downloadTimeout)
+	var downloadedFilePath string
+	err = step("download", func(opt request.Option) (err error) {
+		downloadedFilePath, err = DownloadFileFromS3(ctx, cfg, target, probe, opt)
+		return err
+	})
 	if err != nil {
-		cfg.Logger.Error("Download failed", slog.String("file", fileName), slog.Any("error", err))
-		return
+		cfg.Logger.Error("Download failed", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		return run
 	}
 
-	CheckFileIntegrity(cfg, localFilePath, downloadedFilePath, fileName)
+	step("verify", func(request.Option) error {
+		if localFilePath == "" {
+			return CheckPayloadIntegrity(cfg, target, probe, downloadedFilePath)
+		}
+		return CheckFileIntegrity(cfg, target, localFilePath, downloadedFilePath, fileName)
+	})
 	err = os.Remove(downloadedFilePath)
 	if err != nil {
 		cfg.Logger.Warn("Failed to remove downloaded file", slog.String("file", downloadedFilePath), slog.Any("error", err))
 	}
 
-	err = DeleteFileFromS3(cfg, fileName, deleteTimeout)
+	err = step("delete", func(opt request.Option) error { return DeleteFileFromS3(ctx, cfg, target, probe, opt) })
 	if err != nil {
-		cfg.Logger.Error("Delete failed", slog.String("file", fileName), slog.Any("error", err))
-		return
+		cfg.Logger.Error("Delete failed", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		return run
 	}
+	return run
 }
 
-func CreateSessionWithHTTP2(cfg *config.Config) (*session.Session, error) {
-	tr := &http.Transport{
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-		ForceAttemptHTTP2: true,
+func CreateSessionWithHTTP2(cfg *config.Config, target *config.Target) (*session.Session, error) {
+	creds, err := TargetCredentials(cfg, target)
+	if err != nil {
+		return nil, err
 	}
-	client := &http.Client{Transport: tr}
 	sess, err := session.NewSession(&aws.Config{
-		Endpoint:         aws.String(cfg.S3Endpoint),
-		Region:           aws.String(cfg.S3Region),
-		Credentials:      credentials.NewStaticCredentials(cfg.S3AccessKey, cfg.S3SecretKey, ""),
-		S3ForcePathStyle: aws.Bool(true),
-		HTTPClient:       client,
+		Endpoint:         aws.String(target.S3Endpoint),
+		Region:           aws.String(target.S3Region),
+		Credentials:      creds,
+		S3ForcePathStyle: aws.Bool(target.AddressingStyle != config.AddressingVirtual),
+		HTTPClient:       newHTTPClient(),
 		LogLevel:         aws.LogLevel(cfg.AwsLogLevel),
 	})
 	return sess, err
 }
 
-func UploadFileToS3(cfg *config.Config, filePath, fileName string, fileSize, uploadTimeout int) error {
+func newHTTPClient() *http.Client {
+	tr := &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}
+	return &http.Client{Transport: tr}
+}
+
+func UploadFileToS3(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, opts ...request.Option) (err error) {
 	start := time.Now()
+	fileName := probe.FileName
+
+	var retries retryObserver
+	defer func() { retries.record(target, fileName, "upload", err) }()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(uploadTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, probe.UploadTimeout)
 	defer cancel()
 
-	sess, err := CreateSessionWithHTTP2(cfg)
+	sess, err := CreateSessionWithHTTP2(cfg, target)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Open(filePath)
+	file, err := openPayload(probe)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	var result *s3manager.UploadOutput
-	if fileSize < cfg.MinFileSizeForMultipart {
-		svc := s3.New(sess)
+	if !usesMultipart(cfg, probe) {
+		svc := NewS3Client(sess, target)
 		_, err = svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
-			Bucket: aws.String(cfg.S3Bucket),
-			Key:    aws.String(fileName),
+			Bucket: aws.String(target.S3Bucket),
+			Key:    aws.String(probe.ObjectKey()),
 			Body:   file,
-		})
+		}, retries.requestOptions(probe, opts...)...)
 	} else {
-		uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
-			u.PartSize = int64(cfg.MinFileSizeForMultipart) * 1024 * 1024
+		uploader := s3manager.NewUploaderWithClient(NewS3Client(sess, target), func(u *s3manager.Uploader) {
+			// MIN_FILE_SIZE_FOR_MULTIPART задается в байтах; S3 не принимает части меньше 5 MiB
+			u.PartSize = max(int64(cfg.MinFileSizeForMultipart), s3manager.MinUploadPartSize)
 			u.Concurrency = cfg.ConcurrencyMPU
+			u.RequestOptions = retries.requestOptions(probe, opts...)
 		})
 		result, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-			Bucket: aws.String(cfg.S3Bucket),
-			Key:    aws.String(fileName),
+			Bucket: aws.String(target.S3Bucket),
+			Key:    aws.String(probe.ObjectKey()),
 			Body:   file,
 		})
 	}
 
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
 	if ctx.Err() != nil {
-		cfg.Logger.Warn("Upload operation timed out", slog.String("file", fileName))
-		metrics.TimeoutMetric.WithLabelValues(fileName, "upload").Set(1)
+		cfg.Logger.Warn("Upload operation timed out", slog.String("target", target.Name), slog.String("file", fileName))
+		metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "upload")...).Set(1)
 		return ctx.Err()
 	}
 
 	if err != nil {
-		cfg.Logger.Error("Upload failed", slog.String("file", fileName), slog.Any("error", err))
-		metrics.IsError.WithLabelValues(fileName, "upload").Set(1)
+		cfg.Logger.Error("Upload failed", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		metrics.IsError.WithLabelValues(labels(target, fileName, "upload")...).Set(1)
 		return err
 	}
 
 	duration := time.Since(start).Seconds()
-	metrics.UploadDuration.WithLabelValues(fileName).Set(duration)
-	metrics.IsError.WithLabelValues(fileName, "upload").Set(0)
-	metrics.TimeoutMetric.WithLabelValues(fileName, "upload").Set(0)
+	metrics.UploadDuration.WithLabelValues(labels(target, fileName)...).Set(duration)
+	metrics.IsError.WithLabelValues(labels(target, fileName, "upload")...).Set(0)
+	metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "upload")...).Set(0)
 
 	if result != nil {
-		cfg.Logger.Info("File uploaded successfully", slog.String("file", fileName), slog.String("etag", aws.StringValue(result.ETag)))
+		cfg.Logger.Info("File uploaded successfully", slog.String("target", target.Name), slog.String("file", fileName), slog.String("etag", aws.StringValue(result.ETag)))
 	} else {
-		cfg.Logger.Info("File uploaded successfully using PutObject", slog.String("file", fileName))
+		cfg.Logger.Info("File uploaded successfully using PutObject", slog.String("target", target.Name), slog.String("file", fileName))
 	}
 	return nil
 }
 
-func DownloadFileFromS3(cfg *config.Config, fileName string, downloadTimeout int) (string, error) {
+func DownloadFileFromS3(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, opts ...request.Option) (_ string, err error) {
 	start := time.Now()
+	fileName := probe.FileName
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(downloadTimeout)*time.Second)
+	var retries retryObserver
+	defer func() { retries.record(target, fileName, "download", err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, probe.DownloadTimeout)
 	defer cancel()
 
-	sess, err := CreateSessionWithHTTP2(cfg)
+	sess, err := CreateSessionWithHTTP2(cfg, target)
 	if err != nil {
 		return "", err
 	}
 
-	tempFileName := fmt.Sprintf("%s-tmp", fileName)
-	tempFilePath := filepath.Join(cfg.FilesDir, tempFileName)
+	tempFilePath := probe.DownloadPath(target.FilesDir)
 	tempFile, err := os.Create(tempFilePath)
 	if err != nil {
 		return "", err
 	}
 	defer tempFile.Close()
 
-	svc := s3.New(sess)
+	svc := NewS3Client(sess, target)
 	resp, err := svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(cfg.S3Bucket),
-		Key:    aws.String(fileName),
-	})
+		Bucket: aws.String(target.S3Bucket),
+		Key:    aws.String(probe.ObjectKey()),
+	}, retries.requestOptions(probe, opts...)...)
 	if err != nil {
 		return "", err
 	}
@@ -159,63 +212,158 @@ func DownloadFileFromS3(cfg *config.Config, fileName string, downloadTimeout int
 
 	_, err = io.Copy(tempFile, resp.Body)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return "", ctx.Err()
+		}
 		if ctx.Err() != nil {
-			cfg.Logger.Warn("Download operation timed out", slog.String("file", fileName))
-			metrics.TimeoutMetric.WithLabelValues(fileName, "download").Set(1)
-			metrics.IsError.WithLabelValues(fileName, "download").Set(0)
+			cfg.Logger.Warn("Download operation timed out", slog.String("target", target.Name), slog.String("file", fileName))
+			metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "download")...).Set(1)
+			metrics.IsError.WithLabelValues(labels(target, fileName, "download")...).Set(0)
 			return "", ctx.Err()
 		}
-		cfg.Logger.Error("Failed to write data to temporary file", slog.String("file", fileName), slog.Any("error", err))
-		metrics.IsError.WithLabelValues(fileName, "download").Set(1)
-		metrics.TimeoutMetric.WithLabelValues(fileName, "download").Set(0)
+		cfg.Logger.Error("Failed to write data to temporary file", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		metrics.IsError.WithLabelValues(labels(target, fileName, "download")...).Set(1)
+		metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "download")...).Set(0)
 		return "", err
 	}
-	cfg.Logger.Info("File downloaded successfully", slog.String("file", fileName))
+	cfg.Logger.Info("File downloaded successfully", slog.String("target", target.Name), slog.String("file", fileName))
 	duration := time.Since(start).Seconds()
-	metrics.DownloadDuration.WithLabelValues(fileName).Set(duration)
-	metrics.TimeoutMetric.WithLabelValues(fileName, "download").Set(0)
-	metrics.IsError.WithLabelValues(fileName, "download").Set(0)
+	metrics.DownloadDuration.WithLabelValues(labels(target, fileName)...).Set(duration)
+	metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "download")...).Set(0)
+	metrics.IsError.WithLabelValues(labels(target, fileName, "download")...).Set(0)
 
 	return tempFilePath, nil
 }
 
-func DeleteFileFromS3(cfg *config.Config, fileName string, deleteTimeout int) error {
+func DeleteFileFromS3(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, opts ...request.Option) (err error) {
 	start := time.Now()
+	fileName := probe.FileName
 	defer func() {
 		duration := time.Since(start).Seconds()
-		metrics.DeleteDuration.WithLabelValues(fileName).Set(duration)
+		metrics.DeleteDuration.WithLabelValues(labels(target, fileName)...).Set(duration)
 	}()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(deleteTimeout)*time.Second)
+	var retries retryObserver
+	defer func() { retries.record(target, fileName, "delete", err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, probe.DeleteTimeout)
 	defer cancel()
 
-	sess, err := CreateSessionWithHTTP2(cfg)
+	sess, err := CreateSessionWithHTTP2(cfg, target)
 	if err != nil {
 		return err
 	}
 
-	svc := s3.New(sess)
+	svc := NewS3Client(sess, target)
 	_, err = svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(cfg.S3Bucket),
-		Key:    aws.String(fileName),
-	})
+		Bucket: aws.String(target.S3Bucket),
+		Key:    aws.String(probe.ObjectKey()),
+	}, retries.requestOptions(probe, opts...)...)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
 	if ctx.Err() != nil {
-		cfg.Logger.Warn("Delete operation timed out", slog.String("file", fileName))
-		metrics.TimeoutMetric.WithLabelValues(fileName, "delete").Set(1)
+		cfg.Logger.Warn("Delete operation timed out", slog.String("target", target.Name), slog.String("file", fileName))
+		metrics.TimeoutMetric.WithLabelValues(labels(target, fileName, "delete")...).Set(1)
 		return ctx.Err()
 	}
 
 	if err != nil {
-		cfg.Logger.Error("Delete failed", slog.String("file", fileName), slog.Any("error", err))
-		metrics.IsError.WithLabelValues(fileName, "delete").Set(1)
+		cfg.Logger.Error("Delete failed", slog.String("target", target.Name), slog.String("file", fileName), slog.Any("error", err))
+		metrics.IsError.WithLabelValues(labels(target, fileName, "delete")...).Set(1)
 		return err
 	}
 
-	cfg.Logger.Info("File deleted successfully", slog.String("file", fileName))
+	cfg.Logger.Info("File deleted successfully", slog.String("target", target.Name), slog.String("file", fileName))
 	return nil
 }
 
-func CheckFileIntegrity(cfg *config.Config, originalFilePath, downloadedFilePath, fileName string) {
+// payloadReader - содержимое загружаемого объекта.
+type payloadReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// openPayload открывает файл пробы или, если файл не задан, генерирует содержимое по PayloadSeed.
+func openPayload(probe *config.Probe) (payloadReader, error) {
+	if probe.TempFile == "" {
+		return payload.New(probe.PayloadSeed, int64(probe.FileSizeBytes)), nil
+	}
+	return os.Open(probe.TempFile)
+}
+
+// usesMultipart сообщает, что файл пробы загружается с помощью multipart upload.
+func usesMultipart(cfg *config.Config, probe *config.Probe) bool {
+	return probe.FileSizeBytes >= cfg.MinFileSizeForMultipart
+}
+
+// CleanupProbe удаляет объект пробы из бакета и прерывает незавершенные multipart upload этого объекта,
+// которые могли остаться после прерванного сценария.
+func CleanupProbe(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe) error {
+	sess, err := CreateSessionWithHTTP2(cfg, target)
+	if err != nil {
+		return err
+	}
+	svc := NewS3Client(sess, target)
+
+	if usesMultipart(cfg, probe) {
+		uploads, err := svc.ListMultipartUploadsWithContext(ctx, &s3.ListMultipartUploadsInput{
+			Bucket: aws.String(target.S3Bucket),
+			Prefix: aws.String(probe.ObjectKey()),
+		})
+		if err != nil {
+			return fmt.Errorf("list multipart uploads: %w", err)
+		}
+		for _, upload := range uploads.Uploads {
+			if aws.StringValue(upload.Key) != probe.ObjectKey() {
+				continue
+			}
+			_, err = svc.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(target.S3Bucket),
+				Key:      upload.Key,
+				UploadId: upload.UploadId,
+			})
+			if err != nil {
+				return fmt.Errorf("abort multipart upload %s: %w", aws.StringValue(upload.UploadId), err)
+			}
+			cfg.Logger.Info("Aborted multipart upload", slog.String("target", target.Name), slog.String("file", probe.FileName), slog.String("upload_id", aws.StringValue(upload.UploadId)))
+		}
+	}
+
+	_, err = svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(target.S3Bucket),
+		Key:    aws.String(probe.ObjectKey()),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
+
+// CheckPayloadIntegrity проверяет, что скачанный файл совпадает с содержимым, сгенерированным для пробы.
+func CheckPayloadIntegrity(cfg *config.Config, target *config.Target, probe *config.Probe, downloadedFilePath string) error {
+	file, err := os.Open(downloadedFilePath)
+	if err != nil {
+		cfg.Logger.Error("Failed to read downloaded file", slog.String("file", downloadedFilePath), slog.Any("error", err))
+		return err
+	}
+	defer file.Close()
+
+	if err = payload.Verify(file, probe.PayloadSeed, int64(probe.FileSizeBytes)); err != nil {
+		if !errors.Is(err, payload.ErrMismatch) {
+			cfg.Logger.Error("Failed to read downloaded file", slog.String("file", downloadedFilePath), slog.Any("error", err))
+			return err
+		}
+		cfg.Logger.Warn("File integrity check failed", slog.String("target", target.Name), slog.String("file", probe.FileName), slog.Any("error", err))
+		metrics.FileIsCorrected.WithLabelValues(labels(target, probe.FileName)...).Set(0)
+		return fmt.Errorf("%w: %v", ErrIntegrityCheckFailed, err)
+	}
+	cfg.Logger.Info("File integrity check passed", slog.String("target", target.Name), slog.String("file", probe.FileName))
+	metrics.FileIsCorrected.WithLabelValues(labels(target, probe.FileName)...).Set(1)
+	return nil
+}
+
+func CheckFileIntegrity(cfg *config.Config, target *config.Target, originalFilePath, downloadedFilePath, fileName string) error {
 	// Создаем хешеры для обоих файлов
 	originalHasher := md5.New()
 	downloadedHasher := md5.New()
@@ -249,12 +397,12 @@ func CheckFileIntegrity(cfg *config.Config, originalFilePath, downloadedFilePath
 	// Вычисляем хеши для обоих файлов
 	if err := calculateHash(originalFilePath, originalHasher); err != nil {
 		cfg.Logger.Error("Failed to read original file", slog.String("file", originalFilePath), slog.Any("error", err))
-		return
+		return err
 	}
 
 	if err := calculateHash(downloadedFilePath, downloadedHasher); err != nil {
 		cfg.Logger.Error("Failed to read downloaded file", slog.String("file", downloadedFilePath), slog.Any("error", err))
-		return
+		return err
 	}
 
 	// Получаем результаты хеширования
@@ -263,10 +411,141 @@ func CheckFileIntegrity(cfg *config.Config, originalFilePath, downloadedFilePath
 
 	// Сравниваем хеши
 	if originalHash != downloadedHash {
-		cfg.Logger.Warn("File integrity check failed", slog.String("file", fileName))
-		metrics.FileIsCorrected.WithLabelValues(fileName).Set(0)
-	} else {
-		cfg.Logger.Info("File integrity check passed", slog.String("file", fileName))
-		metrics.FileIsCorrected.WithLabelValues(fileName).Set(1)
+		cfg.Logger.Warn("File integrity check failed", slog.String("target", target.Name), slog.String("file", fileName))
+		metrics.FileIsCorrected.WithLabelValues(labels(target, fileName)...).Set(0)
+		return ErrIntegrityCheckFailed
+	}
+	cfg.Logger.Info("File integrity check passed", slog.String("target", target.Name), slog.String("file", fileName))
+	metrics.FileIsCorrected.WithLabelValues(labels(target, fileName)...).Set(1)
+	return nil
+}
+
+// RunStep выполняет операцию сценария и добавляет ее результат в run.
+// Опция, передаваемая в fn, запоминает ID последнего запроса операции к S3.
+func RunStep(run *results.Run, observer results.Observer, operation string, fn func(opt request.Option) error) error {
+	key := results.Key{Target: run.Target, File: run.File}
+	if observer != nil {
+		observer.StepStarted(key, operation)
+	}
+	var requestID requestIDRecorder
+	start := time.Now()
+	err := fn(requestID.option)
+	step := results.Step{
+		Operation: operation,
+		Started:   start,
+		Duration:  time.Since(start).Seconds(),
+		Outcome:   stepOutcome(err),
+		RequestID: requestID.get(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+		step.ErrorClass = ErrorClass(err)
 	}
+	run.Steps = append(run.Steps, step)
+	if observer != nil {
+		observer.StepFinished(key, step)
+	}
+	return err
+}
+
+func stepOutcome(err error) string {
+	switch {
+	case err == nil:
+		return results.OutcomeOK
+	case errors.Is(err, ErrIntegrityCheckFailed):
+		return results.OutcomeIntegrityFailed
+	case isTimeout(err):
+		return results.OutcomeTimeout
+	default:
+		return results.OutcomeError
+	}
+}
+
+// isTimeout сообщает, что операция прервана по таймауту контекста.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == request.CanceledErrorCode && errors.Is(aerr.OrigErr(), context.DeadlineExceeded)
+}
+
+// Классы ошибок шага сценария.
+const (
+	ErrorClassTimeout    = "timeout"
+	ErrorClassCanceled   = "canceled"
+	ErrorClassNetwork    = "network"
+	ErrorClassAuth       = "auth"
+	ErrorClassNotFound   = "not_found"
+	ErrorClassThrottling = "throttling"
+	ErrorClassServer     = "server"
+	ErrorClassClient     = "client"
+	ErrorClassIntegrity  = "integrity"
+	ErrorClassLocal      = "local"
+	ErrorClassUnknown    = "unknown"
+)
+
+// ErrorClass относит ошибку операции к одному из классов, чтобы по нему можно было
+// быстро понять причину: таймаут, сеть, авторизация, ответ сервера и т.д.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrIntegrityCheckFailed) {
+		return ErrorClassIntegrity
+	}
+	if isTimeout(err) {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return ErrorClassLocal
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return ErrorClassNetwork
+		}
+		return ErrorClassUnknown
+	}
+
+	switch aerr.Code() {
+	case request.CanceledErrorCode:
+		return ErrorClassCanceled
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.ErrCodeRead:
+		return ErrorClassNetwork
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken", "RequestTimeTooSkewed":
+		return ErrorClassAuth
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return ErrorClassNotFound
+	case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequests":
+		return ErrorClassThrottling
+	}
+
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		switch status := reqErr.StatusCode(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return ErrorClassAuth
+		case status == http.StatusNotFound:
+			return ErrorClassNotFound
+		case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+			return ErrorClassThrottling
+		case status >= 500:
+			return ErrorClassServer
+		case status >= 400:
+			return ErrorClassClient
+		}
+	}
+	return ErrorClassUnknown
+}
+
+// labels возвращает значения меток метрики: цель, эндпоинт, бакет, файл и дополнительные метки.
+func labels(target *config.Target, fileName string, extra ...string) []string {
+	return append([]string{target.Name, target.S3Endpoint, target.S3Bucket, fileName}, extra...)
 }
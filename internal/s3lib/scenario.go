@@ -0,0 +1,67 @@
+package s3lib
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+)
+
+// RunScenario выполняет операции сценария над объектом пробы по порядку и останавливается на первой неуспешной.
+// Сценарий должен быть проверен config.ValidateScenario. list запрашивает объекты с префиксом, равным ключу пробы.
+func RunScenario(ctx context.Context, cfg *config.Config, target *config.Target, probe *config.Probe, operations []string, observer results.Observer) (run results.Run) {
+	run = results.Run{Target: target.Name, File: probe.FileName, Size: probe.FileSizeBytes, Started: time.Now()}
+	defer func() {
+		run.Finished = time.Now()
+		run.OK = run.Failure() == nil
+	}()
+
+	var downloadedFilePath string
+	defer func() {
+		if downloadedFilePath != "" {
+			os.Remove(downloadedFilePath)
+		}
+	}()
+	for _, operation := range operations {
+		var fn func(opt request.Option) error
+		switch operation {
+		case config.OperationUpload:
+			fn = func(opt request.Option) error { return UploadFileToS3(ctx, cfg, target, probe, opt) }
+		case config.OperationDownload:
+			fn = func(opt request.Option) (err error) {
+				downloadedFilePath, err = DownloadFileFromS3(ctx, cfg, target, probe, opt)
+				return err
+			}
+		case config.OperationVerify:
+			fn = func(request.Option) error {
+				if probe.TempFile == "" {
+					return CheckPayloadIntegrity(cfg, target, probe, downloadedFilePath)
+				}
+				return CheckFileIntegrity(cfg, target, probe.TempFile, downloadedFilePath, probe.FileName)
+			}
+		case config.OperationGet:
+			fn = func(opt request.Option) error {
+				_, err := GetObject(ctx, cfg, target, probe, opt)
+				return err
+			}
+		case config.OperationHead:
+			fn = func(opt request.Option) error { return HeadObject(ctx, cfg, target, probe, opt) }
+		case config.OperationList:
+			fn = func(opt request.Option) error {
+				_, err := ListObjects(ctx, cfg, target, probe, probe.ObjectKey(), opt)
+				return err
+			}
+		case config.OperationDelete:
+			fn = func(opt request.Option) error { return DeleteFileFromS3(ctx, cfg, target, probe, opt) }
+		}
+		if err := RunStep(&run, observer, operation, fn); err != nil {
+			cfg.Logger.Error("Scenario step failed", slog.String("target", target.Name), slog.String("file", probe.FileName), slog.String("operation", operation), slog.Any("error", err))
+			return run
+		}
+	}
+	return run
+}
@@ -0,0 +1,308 @@
+package s3lib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"s3syn-test/internal/config"
+)
+
+const (
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	emptySHA256      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// Размер блока aws-chunked тела запроса
+	streamingChunkSize = 64 * 1024
+)
+
+// NewS3Client создает клиент S3 с настройками подписи запросов цели.
+func NewS3Client(sess *session.Session, target *config.Target) *s3.S3 {
+	svc := s3.New(sess)
+	if target.SignatureVersion == config.SignatureV2 {
+		svc.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+			Name: "s3syn.SignV2Handler",
+			Fn: func(r *request.Request) {
+				signV2(r, target.S3Bucket)
+			},
+		})
+		return svc
+	}
+
+	switch target.PayloadSigning {
+	case config.PayloadUnsigned:
+		svc.Handlers.Sign.PushFrontNamed(request.NamedHandler{
+			Name: "s3syn.UnsignedPayloadHandler",
+			Fn: func(r *request.Request) {
+				r.HTTPRequest.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+			},
+		})
+	case config.PayloadStreaming:
+		svc.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+			Name: "s3syn.StreamingSignHandler",
+			Fn: func(r *request.Request) {
+				setStreamingHeaders(r)
+				v4.SignSDKRequest(r)
+				setStreamingBody(r)
+			},
+		})
+	}
+	return svc
+}
+
+// hasStreamingBody сообщает, передает ли операция тело объекта, которое можно отправить в формате aws-chunked.
+func hasStreamingBody(r *request.Request) bool {
+	return r.Operation.Name == "PutObject" || r.Operation.Name == "UploadPart"
+}
+
+// setStreamingHeaders выставляет заголовки aws-chunked до подписи запроса, чтобы они вошли в подпись.
+func setStreamingHeaders(r *request.Request) {
+	if r.Error != nil || !hasStreamingBody(r) || r.Body == nil {
+		return
+	}
+	decodedLength, err := aws.SeekerLen(r.Body)
+	if err != nil {
+		r.Error = err
+		return
+	}
+	encodedLength := streamingContentLength(decodedLength)
+	header := r.HTTPRequest.Header
+	header.Set("X-Amz-Content-Sha256", streamingPayload)
+	header.Set("Content-Encoding", "aws-chunked")
+	header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decodedLength, 10))
+	header.Set("Content-Length", strconv.FormatInt(encodedLength, 10))
+	r.HTTPRequest.ContentLength = encodedLength
+}
+
+// setStreamingBody оборачивает тело запроса в aws-chunked поток, подписывая каждый блок
+// от подписи заголовков (seed signature).
+func setStreamingBody(r *request.Request) {
+	if r.Error != nil || r.HTTPRequest.Header.Get("X-Amz-Content-Sha256") != streamingPayload {
+		return
+	}
+	seedSignature, err := v4.GetSignedRequestSignature(r.HTTPRequest)
+	if err != nil {
+		r.Error = err
+		return
+	}
+	creds, err := r.Config.Credentials.GetWithContext(r.Context())
+	if err != nil {
+		r.Error = err
+		return
+	}
+	amzDate := r.HTTPRequest.Header.Get("X-Amz-Date")
+	signTime, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		r.Error = err
+		return
+	}
+	region := r.ClientInfo.SigningRegion
+	if region == "" {
+		region = aws.StringValue(r.Config.Region)
+	}
+	scope := strings.Join([]string{signTime.Format("20060102"), region, "s3", "aws4_request"}, "/")
+
+	r.HTTPRequest.Body = io.NopCloser(&chunkedReader{
+		source:        r.GetBody(),
+		signingKey:    signingKey(creds.SecretAccessKey, signTime, region, "s3"),
+		amzDate:       amzDate,
+		scope:         scope,
+		prevSignature: hex.EncodeToString(seedSignature),
+	})
+}
+
+// streamingContentLength возвращает длину тела aws-chunked для тела исходной длины.
+func streamingContentLength(decodedLength int64) int64 {
+	chunkLength := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))) + int64(len(";chunk-signature=")) + 64 + 2 + size + 2
+	}
+	fullChunks := decodedLength / streamingChunkSize
+	length := fullChunks * chunkLength(streamingChunkSize)
+	if rest := decodedLength % streamingChunkSize; rest > 0 {
+		length += chunkLength(rest)
+	}
+	return length + chunkLength(0)
+}
+
+// chunkedReader кодирует тело запроса блоками aws-chunked с цепочкой подписей блоков.
+type chunkedReader struct {
+	source        io.Reader
+	signingKey    []byte
+	amzDate       string
+	scope         string
+	prevSignature string
+
+	buf  bytes.Buffer
+	done bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.buf.Len() == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return c.buf.Read(p)
+}
+
+func (c *chunkedReader) nextChunk() error {
+	data := make([]byte, streamingChunkSize)
+	n, err := io.ReadFull(c.source, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	data = data[:n]
+	c.writeChunk(data)
+	if n == 0 {
+		c.done = true
+	} else if n < streamingChunkSize {
+		// Последний блок данных, за ним следует завершающий пустой блок
+		c.writeChunk(nil)
+		c.done = true
+	}
+	return nil
+}
+
+func (c *chunkedReader) writeChunk(data []byte) {
+	dataHash := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		c.amzDate,
+		c.scope,
+		c.prevSignature,
+		emptySHA256,
+		hex.EncodeToString(dataHash[:]),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(c.signingKey, []byte(stringToSign)))
+	c.prevSignature = signature
+
+	fmt.Fprintf(&c.buf, "%x;chunk-signature=%s\r\n", len(data), signature)
+	c.buf.Write(data)
+	c.buf.WriteString("\r\n")
+}
+
+func signingKey(secretKey string, t time.Time, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(t.Format("20060102")))
+	kRegion := hmacSHA256(kDate, []byte(region))
+	kService := hmacSHA256(kRegion, []byte(service))
+	return hmacSHA256(kService, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Подресурсы, которые входят в подписываемый ресурс Signature Version 2.
+var v2SubResources = map[string]bool{
+	"acl": true, "cors": true, "delete": true, "lifecycle": true, "location": true,
+	"logging": true, "notification": true, "partNumber": true, "policy": true,
+	"requestPayment": true, "tagging": true, "torrent": true, "uploadId": true,
+	"uploads": true, "versionId": true, "versioning": true, "versions": true, "website": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+	"response-content-language": true, "response-content-type": true, "response-expires": true,
+}
+
+// signV2 подписывает запрос к S3 по схеме Signature Version 2.
+func signV2(r *request.Request, bucket string) {
+	creds, err := r.Config.Credentials.GetWithContext(r.Context())
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	header := r.HTTPRequest.Header
+	header.Del("Authorization")
+	header.Del("X-Amz-Date")
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if creds.SessionToken != "" {
+		header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	stringToSign := v2StringToSign(r.HTTPRequest, bucket)
+
+	mac := hmac.New(sha1.New, []byte(creds.SecretAccessKey))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	header.Set("Authorization", "AWS "+creds.AccessKeyID+":"+signature)
+}
+
+// v2StringToSign строит строку для подписи Signature Version 2. Для virtual-hosted запросов
+// имя бакета из заголовка Host добавляется в начало подписываемого ресурса.
+func v2StringToSign(req *http.Request, bucket string) string {
+	header := req.Header
+
+	var amzHeaders []string
+	for name, values := range header {
+		name = strings.ToLower(name)
+		if !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		amzHeaders = append(amzHeaders, name+":"+strings.Join(trimmed, ","))
+	}
+	sort.Strings(amzHeaders)
+
+	resource := req.URL.EscapedPath()
+	if resource == "" {
+		resource = "/"
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if bucket != "" && strings.HasPrefix(host, bucket+".") {
+		resource = "/" + bucket + resource
+	}
+
+	query := req.URL.Query()
+	var subResources []string
+	for name := range query {
+		if v2SubResources[name] {
+			subResources = append(subResources, name)
+		}
+	}
+	sort.Strings(subResources)
+	for i, name := range subResources {
+		if value := query.Get(name); value != "" {
+			subResources[i] = name + "=" + value
+		}
+	}
+	if len(subResources) > 0 {
+		resource += "?" + strings.Join(subResources, "&")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(req.Method + "\n")
+	sb.WriteString(header.Get("Content-Md5") + "\n")
+	sb.WriteString(header.Get("Content-Type") + "\n")
+	sb.WriteString(header.Get("Date") + "\n")
+	for _, h := range amzHeaders {
+		sb.WriteString(h + "\n")
+	}
+	sb.WriteString(resource)
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/metrics"
+)
+
+// Schedule определяет моменты запуска пробы: фиксированный интервал или cron выражение
+// и случайную задержку каждого запуска, чтобы реплики не обращались к S3 одновременно.
+type Schedule struct {
+	interval time.Duration
+	cron     cron.Schedule
+	jitter   time.Duration
+}
+
+// New создает расписание пробы.
+func New(probe *config.Probe) (*Schedule, error) {
+	s := &Schedule{
+		interval: probe.Interval,
+		jitter:   probe.Jitter,
+	}
+	if probe.Cron != "" {
+		var err error
+		s.cron, err = cron.ParseStandard(probe.Cron)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Period возвращает наибольший ожидаемый промежуток между запусками с учетом случайной задержки.
+func (s *Schedule) Period() time.Duration {
+	if s.cron == nil {
+		return s.interval + s.jitter
+	}
+	first := s.cron.Next(time.Now())
+	return s.cron.Next(first).Sub(first) + s.jitter
+}
+
+// next возвращает плановое время запуска, следующее за slot.
+func (s *Schedule) next(slot time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.Next(slot)
+	}
+	return slot.Add(s.interval)
+}
+
+func (s *Schedule) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.jitter)
+}
+
+// Run вызывает fn по расписанию, пока не отменен ctx. Запуски не перекрываются: если fn выполняется дольше
+// периода, пропущенные запуски не выполняются, а следующий начинается сразу. Опоздание запуска
+// относительно планового времени записывается в метрику s3_probe_schedule_lag_seconds.
+func (s *Schedule) Run(ctx context.Context, target *config.Target, probe *config.Probe, fn func()) {
+	labels := []string{target.Name, target.S3Endpoint, target.S3Bucket, probe.FileName}
+	slot := time.Now()
+	if s.cron != nil {
+		slot = s.cron.Next(slot)
+	}
+	for {
+		start := slot.Add(s.randomJitter())
+		timer := time.NewTimer(time.Until(start))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		metrics.ScheduleLag.WithLabelValues(labels...).Set(max(time.Since(start), 0).Seconds())
+		fn()
+
+		// Пропускаем запуски, время которых прошло, пока выполнялась fn
+		slot = s.next(slot)
+		now := time.Now()
+		for next := s.next(slot); !next.After(now); next = s.next(slot) {
+			slot = next
+			metrics.ScheduleMissed.WithLabelValues(labels...).Inc()
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"s3syn-test/internal/config"
+	"s3syn-test/internal/results"
+	"s3syn-test/internal/s3lib"
+)
+
+// KeyPrefix - префикс ключей объектов разовых проб.
+const KeyPrefix = "adhoc-"
+
+// OnDemandInfix отделяет ключ пробы от суффикса ключа ее запуска по запросу.
+const OnDemandInfix = "-ondemand-"
+
+// adhocFile - имя разовой пробы в результатах и метриках.
+const adhocFile = "adhoc"
+
+// maxRuns ограничивает число одновременно выполняемых по запросу проб.
+const maxRuns = 4
+
+// Source возвращает текущие цели и пробы, которые могут измениться во время работы.
+type Source interface {
+	Config() *config.Config
+	TargetProbes(target string) []config.Probe
+}
+
+// Trigger выполняет пробы по HTTP запросу.
+type Trigger struct {
+	cfg      *config.Config
+	store    *results.Store
+	observer results.Observer
+	source   Source
+	slots    chan struct{}
+}
+
+// New создает Trigger. Результаты настроенных проб сохраняются в store, observer получает уведомления о шагах,
+// цели и пробы берутся из source.
+func New(cfg *config.Config, store *results.Store, observer results.Observer, source Source) *Trigger {
+	return &Trigger{cfg: cfg, store: store, observer: observer, source: source, slots: make(chan struct{}, maxRuns)}
+}
+
+// RunRequest - тело запроса на выполнение пробы. Если задан File, выполняется настроенная проба,
+// иначе разовая проба с указанными размером и таймаутами. Незаданные параметры разовой пробы
+// берутся из первой пробы цели.
+type RunRequest struct {
+	Target          string       `json:"target"`
+	File            string       `json:"file"`
+	SizeBytes       config.Value `json:"size_bytes"`               // Число байт или "4KiB", "1.5GB"
+	UploadTimeout   config.Value `json:"upload_timeout_seconds"`   // Число секунд или "250ms", "1m30s"
+	DownloadTimeout config.Value `json:"download_timeout_seconds"` // Число секунд или длительность
+	DeleteTimeout   config.Value `json:"delete_timeout_seconds"`
+	Scenario        []string     `json:"scenario"` // По умолчанию upload, download, verify, delete
+}
+
+// HandleRun выполняет пробу и возвращает результат каждого шага. Объект пробы получает отдельный ключ,
+// чтобы не пересекаться с запусками по расписанию, и удаляется после выполнения.
+func (t *Trigger) HandleRun(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+	if err := config.ValidateScenario(req.Scenario); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	target, probe, err := t.probe(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	default:
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("%d probes are already running", maxRuns))
+		return
+	}
+
+	// Проба выполняется с текущей конфигурацией, которая могла измениться после перезагрузки
+	cfg := t.source.Config()
+	t.cfg.Logger.Info("Running probe on demand", slog.String("target", target.Name), slog.String("file", probe.FileName), slog.String("key", probe.ObjectKey()))
+	var run results.Run
+	if len(req.Scenario) == 0 {
+		run = s3lib.ProcessFile(r.Context(), cfg, target, &probe, t.observer)
+	} else {
+		run = s3lib.RunScenario(r.Context(), cfg, target, &probe, req.Scenario, t.observer)
+	}
+	// Приостановленная проба не зарегистрирована в хранилище: ее результат не сохраняется, чтобы она
+	// не учитывалась в readiness
+	if req.File != "" && slices.Contains(t.store.Keys(), results.Key{Target: target.Name, File: probe.FileName}) {
+		t.store.Record(run)
+	}
+
+	// Объект мог остаться после неуспешного шага или сценария без delete
+	ctx, cancel := context.WithTimeout(context.Background(), probe.DeleteTimeout)
+	defer cancel()
+	if err := s3lib.CleanupProbe(ctx, cfg, target, &probe); err != nil {
+		t.cfg.Logger.Warn("Failed to clean up probe objects", slog.String("target", target.Name), slog.String("key", probe.ObjectKey()), slog.Any("error", err))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(run)
+}
+
+var errNotFound = errors.New("not found")
+
+// probe возвращает цель и пробу для запроса.
+func (t *Trigger) probe(req *RunRequest) (*config.Target, config.Probe, error) {
+	var target *config.Target
+	targets := t.source.Config().Targets
+	switch {
+	case req.Target != "":
+		for i := range targets {
+			if targets[i].Name == req.Target {
+				target = &targets[i]
+			}
+		}
+		if target == nil {
+			return nil, config.Probe{}, fmt.Errorf("target %q %w", req.Target, errNotFound)
+		}
+	case len(targets) == 1:
+		target = &targets[0]
+	default:
+		return nil, config.Probe{}, errors.New("target is required when several targets are configured")
+	}
+
+	probes := t.source.TargetProbes(target.Name)
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	if req.File != "" {
+		if req.SizeBytes != "" || req.UploadTimeout != "" || req.DownloadTimeout != "" || req.DeleteTimeout != "" {
+			return nil, config.Probe{}, errors.New("size and timeouts can only be set for an ad-hoc probe without file")
+		}
+		for _, probe := range probes {
+			if probe.FileName == req.File {
+				probe.Key = probe.ObjectKey() + OnDemandInfix + suffix
+				return target, probe, nil
+			}
+		}
+		return nil, config.Probe{}, fmt.Errorf("probe %q of target %q %w", req.File, target.Name, errNotFound)
+	}
+
+	probe := config.Probe{FileSizeBytes: 1024, UploadTimeout: 10 * time.Second, DownloadTimeout: 10 * time.Second, DeleteTimeout: 10 * time.Second}
+	if len(probes) > 0 {
+		probe = probes[0]
+	}
+	probe.FileName = adhocFile
+	probe.Key = KeyPrefix + suffix
+	probe.TempFile = ""
+	probe.PayloadSeed = rand.Uint64()
+	probe.Scenario, probe.Labels = nil, nil
+	// Размер и таймауты ограничены наибольшими значениями проб цели, чтобы запрос не мог загрузить объект
+	// произвольного размера или надолго занять слот выполнения
+	limit := probe
+	for _, p := range probes {
+		limit.FileSizeBytes = max(limit.FileSizeBytes, p.FileSizeBytes)
+		limit.UploadTimeout = max(limit.UploadTimeout, p.UploadTimeout)
+		limit.DownloadTimeout = max(limit.DownloadTimeout, p.DownloadTimeout)
+		limit.DeleteTimeout = max(limit.DeleteTimeout, p.DeleteTimeout)
+	}
+	if req.SizeBytes != "" {
+		size, err := config.ParseSize(string(req.SizeBytes))
+		if err != nil || size < 0 {
+			return nil, config.Probe{}, fmt.Errorf("size_bytes must be a non-negative size, got %q", req.SizeBytes)
+		}
+		if size > limit.FileSizeBytes {
+			return nil, config.Probe{}, fmt.Errorf("size_bytes must not exceed the largest probe of the target, %d bytes", limit.FileSizeBytes)
+		}
+		probe.FileSizeBytes = size
+	}
+	for _, field := range []struct {
+		name  string
+		value config.Value
+		dst   *time.Duration
+		limit time.Duration
+	}{
+		{"upload_timeout_seconds", req.UploadTimeout, &probe.UploadTimeout, limit.UploadTimeout},
+		{"download_timeout_seconds", req.DownloadTimeout, &probe.DownloadTimeout, limit.DownloadTimeout},
+		{"delete_timeout_seconds", req.DeleteTimeout, &probe.DeleteTimeout, limit.DeleteTimeout},
+	} {
+		if field.value == "" {
+			continue
+		}
+		timeout, err := config.ParseDuration(string(field.value), time.Second)
+		if err != nil || timeout <= 0 {
+			return nil, config.Probe{}, fmt.Errorf("%s must be a positive duration, got %q", field.name, field.value)
+		}
+		if timeout > field.limit {
+			return nil, config.Probe{}, fmt.Errorf("%s must not exceed the largest timeout of the target probes, %s", field.name, field.limit)
+		}
+		*field.dst = timeout
+	}
+	return target, probe, nil
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
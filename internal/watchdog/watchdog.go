@@ -0,0 +1,119 @@
+package watchdog
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"s3syn-test/internal/results"
+)
+
+// Watchdog отслеживает ход выполнения проб и определяет зависшие итерации.
+type Watchdog struct {
+	mu     sync.Mutex
+	probes map[results.Key]*probeState
+	order  []results.Key
+}
+
+type probeState struct {
+	limit        time.Duration
+	lastProgress time.Time
+	operation    string
+	since        time.Time
+}
+
+// Stall описывает пробу, итерация которой не завершилась вовремя.
+type Stall struct {
+	Target    string    `json:"target"`
+	File      string    `json:"file"`
+	Operation string    `json:"operation,omitempty"`
+	Since     time.Time `json:"since"`
+	Limit     string    `json:"limit"`
+}
+
+// New создает новый экземпляр Watchdog.
+func New() *Watchdog {
+	return &Watchdog{probes: make(map[results.Key]*probeState)}
+}
+
+// Register добавляет пробу, итерация которой должна завершаться не реже, чем раз в limit.
+func (w *Watchdog) Register(key results.Key, limit time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if state, ok := w.probes[key]; ok {
+		state.limit = limit
+		return
+	}
+	w.probes[key] = &probeState{limit: limit, lastProgress: time.Now()}
+	w.order = append(w.order, key)
+}
+
+// Unregister перестает отслеживать пробу.
+func (w *Watchdog) Unregister(key results.Key) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.probes, key)
+	w.order = slices.DeleteFunc(w.order, func(k results.Key) bool { return k == key })
+}
+
+// StepStarted запоминает операцию, которую выполняет проба.
+func (w *Watchdog) StepStarted(key results.Key, operation string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if state, ok := w.probes[key]; ok {
+		state.operation = operation
+		state.since = time.Now()
+	}
+}
+
+// StepFinished сбрасывает текущую операцию пробы.
+func (w *Watchdog) StepFinished(key results.Key, _ results.Step) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if state, ok := w.probes[key]; ok {
+		state.operation = ""
+	}
+}
+
+// IterationFinished отмечает завершение итерации пробы.
+func (w *Watchdog) IterationFinished(key results.Key) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if state, ok := w.probes[key]; ok {
+		state.lastProgress = time.Now()
+		state.operation = ""
+	}
+}
+
+// Stalls возвращает пробы, итерация которых не завершилась в пределах их лимита.
+// Для пробы, выполняющей операцию, указывается операция и время ее начала.
+func (w *Watchdog) Stalls() []Stall {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var stalls []Stall
+	now := time.Now()
+	for _, key := range w.order {
+		state := w.probes[key]
+		if now.Sub(state.lastProgress) <= state.limit {
+			continue
+		}
+		stall := Stall{
+			Target: key.Target,
+			File:   key.File,
+			Since:  state.lastProgress,
+			Limit:  state.limit.String(),
+		}
+		if state.operation != "" {
+			stall.Operation = state.operation
+			stall.Since = state.since
+		}
+		stalls = append(stalls, stall)
+	}
+	return stalls
+}